@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pgsync/internal/config"
+	"pgsync/internal/db"
+	"pgsync/internal/keyring"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage remembered passwords and connection services",
+}
+
+var credentialsSaveCmd = &cobra.Command{
+	Use:   "save <url>",
+	Short: "Remember the password of a database URL in the keyring",
+	Long:  `Store the password of the URL in the freedesktop Secret Service, or in ~/.pgsync/credentials.enc when no keyring daemon is running. Later URLs for the same user, host, port and database can leave the password out.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		url, err := db.NormalizeURL(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		kr := requireKeyring()
+		if err := db.StorePassword(url, kr); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ Password for %s saved in the %s", db.CredentialKey(url), kr.Name())))
+	},
+}
+
+var credentialsForgetCmd = &cobra.Command{
+	Use:   "forget <url>",
+	Short: "Remove a remembered password from the keyring",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		url, err := db.NormalizeURL(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		kr := requireKeyring()
+		key := db.CredentialKey(url)
+		if err := kr.Delete(key); err != nil {
+			if errors.Is(err, keyring.ErrNotFound) {
+				err = fmt.Errorf("no password stored for %s", key)
+			}
+			exitWithError(err)
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ Forgot the password for " + key))
+	},
+}
+
+var credentialsServicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "List the connection services from pg_service.conf",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names := db.ServiceNames()
+		if len(names) == 0 {
+			fmt.Println("   No services found in " + strings.Join(db.ServiceFiles(), ", "))
+			return
+		}
+		for _, name := range names {
+			url, err := db.ServiceURL(name)
+			if err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("   %-20s %s\n", name, db.RedactURL(url))
+		}
+	},
+}
+
+func requireKeyring() keyring.Keyring {
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError(err)
+	}
+	kr, err := keyring.New(cfg.Credentials.Keyring)
+	if err != nil {
+		exitWithError(err)
+	}
+	if kr == nil {
+		exitWithError(fmt.Errorf("the keyring is turned off (credentials.keyring is \"off\")"))
+	}
+	return kr
+}
+
+// connectionURL reads a database URL or connection string flag, validates
+// it, returns it as a URL with its TLS settings and hands a password
+// remembered in the keyring to the client tools.
+func connectionURL(cmd *cobra.Command, flag string) string {
+	raw, _ := cmd.Flags().GetString(flag)
+	url, err := db.NormalizeURL(raw)
+	if err != nil {
+		exitWithError(err)
+	}
+	url, err = db.WithTLS(url, tlsFlags(cmd, flag))
+	if err != nil {
+		exitWithError(fmt.Errorf("--%s: %w", flag, err))
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return url
+	}
+	defaults := cfg.TLS.Source
+	if flag == "target" {
+		defaults = cfg.TLS.Target
+	}
+	url, err = db.ApplyTLS(url, defaults, cfg.Profiles)
+	if err != nil {
+		exitWithError(fmt.Errorf("--%s: %w", flag, err))
+	}
+	kr, _ := keyring.New(cfg.Credentials.Keyring)
+	if err := db.UseStoredPassword(url, kr); err != nil {
+		exitWithError(fmt.Errorf("--%s: %w", flag, err))
+	}
+	return url
+}
+
+// addEndpointFlags adds the TLS flags --<endpoint>-sslmode, -sslrootcert,
+// -sslcert and -sslkey, which override the URL's own settings, and the SSH
+// tunnel flags --<endpoint>-ssh, -ssh-key and -ssh-known-hosts, which
+// override a matching profile.
+func addEndpointFlags(cmd *cobra.Command, endpoint string) {
+	cmd.Flags().String(endpoint+"-sslmode", "", endpoint+" sslmode: "+strings.Join(db.SSLModes, ", "))
+	cmd.Flags().String(endpoint+"-sslrootcert", "", endpoint+" CA certificate file, or \"system\"")
+	cmd.Flags().String(endpoint+"-sslcert", "", endpoint+" client certificate file")
+	cmd.Flags().String(endpoint+"-sslkey", "", endpoint+" client key file")
+	cmd.Flags().String(endpoint+"-ssh", "", "reach the "+endpoint+" through SSH jump hosts: [user@]host[:port], comma-separated for several hops")
+	cmd.Flags().String(endpoint+"-ssh-key", "", "private key for the "+endpoint+" SSH tunnel (default: ssh-agent and ~/.ssh/id_*)")
+	cmd.Flags().String(endpoint+"-ssh-known-hosts", "", "known_hosts file for the "+endpoint+" SSH tunnel (default ~/.ssh/known_hosts)")
+}
+
+// sshFlags returns the SSH tunnel given on the command line, or nil.
+func sshFlags(cmd *cobra.Command, endpoint string) *config.SSHConfig {
+	jump, _ := cmd.Flags().GetString(endpoint + "-ssh")
+	if jump == "" {
+		return nil
+	}
+	ssh := &config.SSHConfig{}
+	for _, hop := range strings.Split(jump, ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			ssh.Jump = append(ssh.Jump, hop)
+		}
+	}
+	ssh.Key, _ = cmd.Flags().GetString(endpoint + "-ssh-key")
+	ssh.KnownHosts, _ = cmd.Flags().GetString(endpoint + "-ssh-known-hosts")
+	return ssh
+}
+
+func tlsFlags(cmd *cobra.Command, endpoint string) config.TLSConfig {
+	var tls config.TLSConfig
+	tls.SSLMode, _ = cmd.Flags().GetString(endpoint + "-sslmode")
+	tls.RootCert, _ = cmd.Flags().GetString(endpoint + "-sslrootcert")
+	tls.Cert, _ = cmd.Flags().GetString(endpoint + "-sslcert")
+	tls.Key, _ = cmd.Flags().GetString(endpoint + "-sslkey")
+	return tls
+}
+
+func init() {
+	credentialsCmd.AddCommand(credentialsSaveCmd, credentialsForgetCmd, credentialsServicesCmd)
+	rootCmd.AddCommand(credentialsCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Dump a source database into a portable snapshot file",
+	Long:  `Dump the source database together with a manifest (server version, extensions, table list and checksums) so it can be imported later from another machine.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		source := connectionURL(cmd, "source")
+		out, _ := cmd.Flags().GetString("out")
+		typ, _ := cmd.Flags().GetString("type")
+		tables, _ := cmd.Flags().GetStringArray("table")
+		useStorage, _ := cmd.Flags().GetBool("storage")
+		provider, _ := cmd.Flags().GetString("provider")
+
+		migrationType, err := parseMigrationType(typ)
+		if err != nil {
+			exitWithError(err)
+		}
+		if _, err := db.ResolveProvider(provider); err != nil {
+			exitWithError(err)
+		}
+		source = db.WithProviderSSL(source)
+
+		cfg, _, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+		store := snapshotStore(useStorage)
+		opts := db.MigrationOptions{SelectedTables: tables, Hooks: cfg.Hooks, History: cfg.History, Provider: provider, Profiles: cfg.Profiles, SourceSSH: sshFlags(cmd, "source")}
+
+		_, err = runWithProgress(func(progressChan chan<- db.ProgressUpdate) (*db.MigrationStats, error) {
+			return db.NewMigrator(source, "", migrationType, opts, progressChan).Export(store, out)
+		})
+		if err != nil {
+			exitWithError(fmt.Errorf("export failed: %w", err))
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ Snapshot written to " + store.Location(out)))
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("source", "", "source database URL")
+	addEndpointFlags(exportCmd, "source")
+	exportCmd.Flags().String("out", "snapshot.pgsync", "snapshot file to write")
+	exportCmd.Flags().String("type", "full", "what to export: full, schema or data")
+	exportCmd.Flags().StringArray("table", nil, "only export this table (repeatable)")
+	exportCmd.Flags().Bool("storage", false, "write the snapshot to the configured storage backend")
+	exportCmd.Flags().String("provider", "auto", "managed-provider preset: auto, none, supabase, neon, rds or heroku")
+	exportCmd.MarkFlagRequired("source")
+	rootCmd.AddCommand(exportCmd)
+}
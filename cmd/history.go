@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past migrations",
+	Long:  `List past migrations, newest first, from ~/.pgsync/history.jsonl. Filter by status, target and date, and export the records as JSON or CSV for reporting.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, _ := cmd.Flags().GetString("status")
+		target, _ := cmd.Flags().GetString("target")
+		since, _ := cmd.Flags().GetString("since")
+		until, _ := cmd.Flags().GetString("until")
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		asCSV, _ := cmd.Flags().GetBool("csv")
+
+		if asJSON && asCSV {
+			exitWithError(fmt.Errorf("--json and --csv are mutually exclusive"))
+		}
+		filter := db.HistoryFilter{Status: db.MigrationStatus(status), Target: target}
+		switch filter.Status {
+		case "", db.StatusSuccess, db.StatusFailed:
+		default:
+			exitWithError(fmt.Errorf("unknown --status %q (expected success or failed)", status))
+		}
+		var err error
+		if filter.Since, err = parseDate(since); err != nil {
+			exitWithError(fmt.Errorf("invalid --since: %w", err))
+		}
+		if filter.Until, err = parseDate(until); err != nil {
+			exitWithError(fmt.Errorf("invalid --until: %w", err))
+		}
+		if !filter.Until.IsZero() {
+			// --until is inclusive of the whole day.
+			filter.Until = filter.Until.AddDate(0, 0, 1)
+		}
+
+		history, err := db.LoadHistory()
+		if err != nil {
+			exitWithError(err)
+		}
+		history = db.FilterHistory(history, filter)
+		if limit > 0 && len(history) > limit {
+			history = history[:limit]
+		}
+
+		switch {
+		case asJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(history); err != nil {
+				exitWithError(err)
+			}
+		case asCSV:
+			if err := writeHistoryCSV(history); err != nil {
+				exitWithError(err)
+			}
+		default:
+			printHistory(history)
+		}
+	},
+}
+
+// parseDate accepts YYYY-MM-DD in local time; "" means no bound.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+var historyCSVHeader = []string{
+	"id", "timestamp", "status", "migration_type", "source", "target", "duration",
+	"source_bytes", "bytes_dumped", "table_count", "warnings", "backup_path", "log_path",
+	"rolled_back", "error", "pgsync_version", "pg_dump_version",
+}
+
+func writeHistoryCSV(history []db.MigrationRecord) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(historyCSVHeader)
+	for _, r := range history {
+		w.Write([]string{
+			r.ID,
+			r.Timestamp.Format(time.RFC3339),
+			string(r.Status),
+			string(r.MigrationType),
+			r.Source,
+			r.Target,
+			r.Duration,
+			strconv.FormatInt(r.SourceBytes, 10),
+			strconv.FormatInt(r.BytesDumped, 10),
+			strconv.Itoa(r.TableCount),
+			strings.Join(r.Warnings, "; "),
+			r.BackupPath,
+			r.LogPath,
+			strconv.FormatBool(r.DidRollback),
+			r.Error,
+			r.PgsyncVersion,
+			r.PgDumpVersion,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printHistory(history []db.MigrationRecord) {
+	if len(history) == 0 {
+		fmt.Println("   No history found.")
+		return
+	}
+	for _, r := range history {
+		status := ui.SuccessStyle.Render(fmt.Sprintf("%-7s", r.Status))
+		if r.Status == db.StatusFailed {
+			status = ui.ErrorStyle.Render(fmt.Sprintf("%-7s", r.Status))
+		}
+		fmt.Printf("   %s  %s  %-16s %8s  %s\n", status, r.Timestamp.Format("2006-01-02 15:04"), r.MigrationType, r.Duration, r.Target)
+		if r.Error != "" {
+			fmt.Println(ui.HintStyle.Render("      " + r.Error))
+		}
+	}
+}
+
+func init() {
+	historyCmd.Flags().String("status", "", "only show runs with this status: success or failed")
+	historyCmd.Flags().String("target", "", "only show runs whose target URL contains this text")
+	historyCmd.Flags().String("since", "", "only show runs on or after this date (YYYY-MM-DD)")
+	historyCmd.Flags().String("until", "", "only show runs on or before this date (YYYY-MM-DD)")
+	historyCmd.Flags().Int("limit", 0, "show at most this many runs")
+	historyCmd.Flags().Bool("json", false, "print the records as JSON")
+	historyCmd.Flags().Bool("csv", false, "print the records as CSV")
+	rootCmd.AddCommand(historyCmd)
+}
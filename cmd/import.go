@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pgsync/internal/config"
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <snapshot>",
+	Short: "Restore a snapshot file into a target database",
+	Long:  `Run pre-flight checks for the snapshot manifest against the target database, then restore the snapshot into it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := connectionURL(cmd, "target")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		noBackup, _ := cmd.Flags().GetBool("no-backup")
+		force, _ := cmd.Flags().GetBool("force")
+		useStorage, _ := cmd.Flags().GetBool("storage")
+		analyze, _ := cmd.Flags().GetString("analyze")
+		refresh, _ := cmd.Flags().GetBool("refresh-matviews")
+		onActivity, _ := cmd.Flags().GetString("on-activity")
+		waitTimeout, _ := cmd.Flags().GetDuration("wait-timeout")
+		yes, _ := cmd.Flags().GetBool("yes")
+		provider, _ := cmd.Flags().GetString("provider")
+		strategy, _ := cmd.Flags().GetString("strategy")
+
+		switch db.AnalyzeMode(analyze) {
+		case db.AnalyzeFull, db.AnalyzeStages, db.AnalyzeOff:
+		default:
+			exitWithError(fmt.Errorf("unknown --analyze mode %q (expected analyze, stages or off)", analyze))
+		}
+		switch db.ActivityPolicy(onActivity) {
+		case db.ActivityAbort, db.ActivityWait, db.ActivityTerminate:
+		default:
+			exitWithError(fmt.Errorf("unknown --on-activity policy %q (expected abort, wait or terminate)", onActivity))
+		}
+		if _, err := db.ResolveProvider(provider); err != nil {
+			exitWithError(err)
+		}
+		switch db.LoadStrategy(strategy) {
+		case db.LoadTruncate, db.LoadAppend:
+		default:
+			exitWithError(fmt.Errorf("unknown --strategy %q (expected truncate or append)", strategy))
+		}
+		target = db.WithProviderSSL(target)
+
+		snapshot := snapshotStore(useStorage)
+		manifest, err := db.ReadManifest(snapshot, args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+
+		cfg, store, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+		opts := db.MigrationOptions{
+			ParallelJobs: jobs,
+			AutoBackup:   !noBackup,
+			Storage:      store,
+			Hooks:        cfg.Hooks,
+			History:      cfg.History,
+			Profiles:     cfg.Profiles,
+			TargetSSH:    sshFlags(cmd, "target"),
+
+			Analyze:         db.AnalyzeMode(analyze),
+			RefreshMatViews: refresh,
+
+			OnActivity:      db.ActivityPolicy(onActivity),
+			ActivityTimeout: waitTimeout,
+
+			Provider:     provider,
+			LoadStrategy: db.LoadStrategy(strategy),
+		}
+
+		fmt.Println(ui.PromptStyle.Render("Pre-flight Checks"))
+		fmt.Printf("   Snapshot: %s (%s, %s)\n", snapshot.Location(args[0]), manifest.Source, manifest.CreatedAt.Format("2006-01-02 15:04"))
+		estimation, err := db.EstimateFromManifest(manifest, target, opts)
+		if err != nil {
+			exitWithError(err)
+		}
+		printChecks(estimation)
+
+		if estimation.HasBlockers() && !force {
+			exitWithError(fmt.Errorf("pre-flight checks failed (use --force to import anyway)"))
+		}
+
+		if err := confirmProtected(cmd, target, cfg.Profiles); err != nil {
+			exitWithError(err)
+		}
+		if opts.OnActivity == db.ActivityTerminate && !yes && !confirmTerminate(estimation.Sessions) {
+			exitWithError(fmt.Errorf("import cancelled"))
+		}
+
+		stats, err := runWithProgress(func(progressChan chan<- db.ProgressUpdate) (*db.MigrationStats, error) {
+			return db.NewMigrator("", target, manifest.MigrationType, opts, progressChan).Import(snapshot, args[0])
+		})
+		if stats != nil && stats.BackupPath != "" {
+			fmt.Println("Backup: " + stats.BackupPath)
+		}
+		if err != nil {
+			exitWithError(fmt.Errorf("import failed: %w", err))
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ Import completed"))
+	},
+}
+
+// confirmTerminate asks on the terminal before other sessions on the target
+// are terminated.
+func confirmTerminate(sessions []db.TargetSession) bool {
+	fmt.Println(ui.WarningStyle.Render(fmt.Sprintf("⚠ --on-activity terminate will close every other session on the target (%d connected now):", len(sessions))))
+	for _, s := range sessions {
+		fmt.Println("   • " + s.String())
+	}
+	fmt.Print("Terminate them and continue? [y/N] ")
+
+	var answer string
+	fmt.Scanln(&answer)
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+// confirmProtected asks for the database name before a protected target is
+// overwritten, unless --confirm-target already gives it. --yes does not
+// skip this.
+func confirmProtected(cmd *cobra.Command, target string, profiles []config.Profile) error {
+	p := db.TargetProfile(target, profiles)
+	if !p.IsProtected() {
+		return nil
+	}
+	name := db.DatabaseName(target)
+	answer, _ := cmd.Flags().GetString("confirm-target")
+	if answer == "" {
+		fmt.Println(ui.WarningStyle.Render(fmt.Sprintf("⚠ %s is protected by profile %s. The restore drops and recreates its objects.", name, p.Label())))
+		fmt.Print("Type the database name to confirm: ")
+		fmt.Scanln(&answer)
+	}
+	if answer != name {
+		return fmt.Errorf("confirmation %q does not match the target database %s", answer, name)
+	}
+	return nil
+}
+
+func printChecks(estimation *db.EstimationResult) {
+	fmt.Printf("   Source: %s\n", estimation.SourceVersion)
+	fmt.Printf("   Target: %s\n\n", estimation.TargetVersion)
+	for _, c := range estimation.Checks {
+		line := fmt.Sprintf("   ✓ %s: %s", c.Name, c.Message)
+		switch c.Status {
+		case db.StatusYellow:
+			line = ui.WarningStyle.Render(fmt.Sprintf("   ⚠ %s: %s", c.Name, c.Message))
+		case db.StatusRed:
+			line = ui.ErrorStyle.Render(fmt.Sprintf("   ✗ %s: %s", c.Name, c.Message))
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+}
+
+func init() {
+	importCmd.Flags().String("target", "", "target database URL")
+	addEndpointFlags(importCmd, "target")
+	importCmd.Flags().Int("jobs", 4, "parallel restore jobs")
+	importCmd.Flags().Bool("no-backup", false, "skip the safety backup of the target")
+	importCmd.Flags().Bool("force", false, "import even if pre-flight checks fail")
+	importCmd.Flags().String("confirm-target", "", "database name of a protected target, confirming it may be overwritten")
+	importCmd.Flags().Bool("storage", false, "read the snapshot from the configured storage backend")
+	importCmd.Flags().String("analyze", "analyze", "post-restore statistics: analyze, stages (vacuumdb --analyze-in-stages) or off")
+	importCmd.Flags().Bool("refresh-matviews", true, "refresh materialized views after the restore")
+	importCmd.Flags().String("on-activity", "abort", "when other sessions are busy on the target: abort, wait or terminate")
+	importCmd.Flags().Duration("wait-timeout", 5*time.Minute, "how long --on-activity wait waits for the target to become idle")
+	importCmd.Flags().Bool("yes", false, "do not ask before terminating sessions")
+	importCmd.Flags().String("provider", "auto", "managed-provider preset: auto, none, supabase, neon, rds or heroku")
+	importCmd.Flags().String("strategy", "truncate", "data-only snapshots: truncate the tables before loading, or append to them")
+	importCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:     "plan",
+	Aliases: []string{"dry-run"},
+	Short:   "Show what a migration would do without touching the target",
+	Long:    `Run the pre-flight checks, dump the source schema to a temporary file and print the ordered plan: commands, objects the restore drops and recreates on the target, tables with estimated rows, the backup location and the expected duration. Nothing is written to the target. Exits non-zero if a pre-flight check would block the migration.`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		source := connectionURL(cmd, "source")
+		target := connectionURL(cmd, "target")
+		typ, _ := cmd.Flags().GetString("type")
+		tables, _ := cmd.Flags().GetStringArray("table")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		noBackup, _ := cmd.Flags().GetBool("no-backup")
+		strategy, _ := cmd.Flags().GetString("strategy")
+		provider, _ := cmd.Flags().GetString("provider")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		migrationType, err := parseMigrationType(typ)
+		if err != nil {
+			exitWithError(err)
+		}
+		switch db.LoadStrategy(strategy) {
+		case db.LoadTruncate, db.LoadAppend, db.LoadUpsert:
+		default:
+			exitWithError(fmt.Errorf("unknown --strategy %q (expected truncate, append or upsert)", strategy))
+		}
+		if _, err := db.ResolveProvider(provider); err != nil {
+			exitWithError(err)
+		}
+
+		cfg, store, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+		opts := db.MigrationOptions{
+			SelectedTables:  tables,
+			ParallelJobs:    jobs,
+			AutoBackup:      !noBackup,
+			Storage:         store,
+			Hooks:           cfg.Hooks,
+			LoadStrategy:    db.LoadStrategy(strategy),
+			SyncSequences:   true,
+			Analyze:         db.AnalyzeFull,
+			RefreshMatViews: true,
+			OnActivity:      db.ActivityAbort,
+			Provider:        provider,
+			Profiles:        cfg.Profiles,
+			SourceSSH:       sshFlags(cmd, "source"),
+			TargetSSH:       sshFlags(cmd, "target"),
+		}
+		if err := db.URLsAreDifferent(source, target, opts); err != nil {
+			exitWithError(err)
+		}
+
+		plan, err := db.Plan(db.WithProviderSSL(source), db.WithProviderSSL(target), migrationType, opts)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(plan); err != nil {
+				exitWithError(err)
+			}
+		} else {
+			printPlan(plan)
+		}
+
+		if plan.Blocked {
+			exit(1)
+		}
+	},
+}
+
+func printPlan(plan *db.MigrationPlan) {
+	fmt.Println(ui.PromptStyle.Render("Pre-flight Checks"))
+	printChecks(&db.EstimationResult{SourceVersion: plan.SourceVersion, TargetVersion: plan.TargetVersion, Checks: plan.Checks})
+
+	fmt.Println(ui.PromptStyle.Render(fmt.Sprintf("Plan (%s)", plan.MigrationType)))
+	for i, s := range plan.Steps {
+		fmt.Printf("   %2d. %s\n", i+1, s.Description)
+		if s.Command != "" {
+			fmt.Println(ui.HintStyle.Render("       $ " + s.Command))
+		}
+	}
+
+	if len(plan.Drops) > 0 {
+		fmt.Println()
+		fmt.Println(ui.PromptStyle.Render(fmt.Sprintf("Dropped and recreated on target: %d objects (%d exist now)", len(plan.Drops), plan.ExistingDrops())))
+		for _, o := range plan.Drops {
+			name := o.Name
+			if o.Schema != "" {
+				name = o.Schema + "." + o.Name
+			}
+			line := fmt.Sprintf("   %-18s %s", o.Type, name)
+			if o.Exists {
+				line = ui.WarningStyle.Render(line + "  (exists)")
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(plan.Tables) > 0 {
+		fmt.Println()
+		fmt.Println(ui.PromptStyle.Render(fmt.Sprintf("Tables: %d", len(plan.Tables))))
+		for _, t := range plan.Tables {
+			line := fmt.Sprintf("   %-40s ~%d rows  %s", t.Name, t.EstimatedRows, db.FormatBytes(t.Bytes))
+			if t.Strategy != "" {
+				line += "  " + string(t.Strategy)
+			}
+			fmt.Println(line)
+		}
+	}
+
+	fmt.Println()
+	if plan.BackupLocation != "" {
+		fmt.Println("   Backup: " + plan.BackupLocation)
+	} else {
+		fmt.Println(ui.WarningStyle.Render("   Backup: none (rollback is not possible)"))
+	}
+	fmt.Println("   Expected duration: " + plan.ExpectedBasis)
+	for _, e := range plan.Errors {
+		fmt.Println(ui.WarningStyle.Render("   ⚠ " + e))
+	}
+	fmt.Println(ui.SuccessStyle.Render("✓ Dry run only; nothing was written to the target"))
+}
+
+func init() {
+	planCmd.Flags().String("source", "", "source database URL")
+	addEndpointFlags(planCmd, "source")
+	planCmd.Flags().String("target", "", "target database URL")
+	addEndpointFlags(planCmd, "target")
+	planCmd.Flags().String("type", "full", "what to migrate: full, schema or data")
+	planCmd.Flags().StringArray("table", nil, "only migrate this table (repeatable)")
+	planCmd.Flags().Int("jobs", 4, "parallel restore jobs")
+	planCmd.Flags().Bool("no-backup", false, "plan without the safety backup of the target")
+	planCmd.Flags().String("strategy", "truncate", "data-only load strategy: truncate, append or upsert")
+	planCmd.Flags().String("provider", "auto", "managed-provider preset: auto, none, supabase, neon, rds or heroku")
+	planCmd.Flags().Bool("json", false, "print the plan as JSON")
+	planCmd.MarkFlagRequired("source")
+	planCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(planCmd)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"pgsync/internal/db"
+	"pgsync/internal/storage"
+	"pgsync/internal/ui"
+)
+
+func runWithProgress(run func(progressChan chan<- db.ProgressUpdate) (*db.MigrationStats, error)) (*db.MigrationStats, error) {
+	progressChan := make(chan db.ProgressUpdate, 100)
+	done := make(chan struct{})
+
+	go func() {
+		for update := range progressChan {
+			if update.Command != "" {
+				fmt.Printf("%s\n  %s\n", ui.PromptStyle.Render("→ "+update.Message), update.Command)
+			} else {
+				fmt.Println(ui.PromptStyle.Render("→ " + update.Message))
+			}
+		}
+		close(done)
+	}()
+
+	stats, err := run(progressChan)
+	close(progressChan)
+	<-done
+
+	if stats != nil {
+		for _, w := range stats.Warnings {
+			fmt.Println(ui.WarningStyle.Render("⚠ " + w))
+		}
+		if stats.LogPath != "" {
+			fmt.Println("Log saved to: " + stats.LogPath)
+		}
+	}
+	return stats, err
+}
+
+func parseMigrationType(s string) (db.MigrationType, error) {
+	switch s {
+	case "full", "":
+		return db.SchemaAndData, nil
+	case "schema":
+		return db.SchemaOnly, nil
+	case "data":
+		return db.DataOnly, nil
+	}
+	return "", fmt.Errorf("unknown migration type %q (expected full, schema or data)", s)
+}
+
+func snapshotStore(useStorage bool) storage.Storage {
+	if !useStorage {
+		return storage.NewLocal("")
+	}
+	_, store, err := loadConfig()
+	if err != nil {
+		exitWithError(err)
+	}
+	return store
+}
+
+func exitWithError(err error) {
+	fmt.Println(ui.ErrorStyle.Render("✗ " + err.Error()))
+	exit(1)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Restore a backup from the configured storage into a target database",
+	Long:  `Restore a pg_dump archive (for example a safety backup) from the configured storage backend, local or S3-compatible, into the target database.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := connectionURL(cmd, "target")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+
+		cfg, store, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if err := confirmProtected(cmd, target, cfg.Profiles); err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Println(ui.PromptStyle.Render("→ Restoring " + store.Location(args[0]) + "..."))
+		err = db.WithTunnel(target, sshFlags(cmd, "target"), cfg.Profiles, func(target string) error {
+			out, err := db.RestoreBackup(store, args[0], target, jobs)
+			if err != nil {
+				fmt.Println(string(out))
+			}
+			return err
+		})
+		if err != nil {
+			exitWithError(fmt.Errorf("restore failed: %w", err))
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ Restore complete"))
+	},
+}
+
+func init() {
+	restoreCmd.Flags().String("target", "", "target database URL")
+	addEndpointFlags(restoreCmd, "target")
+	restoreCmd.Flags().Int("jobs", 4, "parallel jobs (local archives only)")
+	restoreCmd.Flags().String("confirm-target", "", "database name of a protected target, confirming it may be overwritten")
+	restoreCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(restoreCmd)
+}
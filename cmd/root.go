@@ -3,9 +3,14 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"pgsync/internal/config"
 	"pgsync/internal/db"
+	"pgsync/internal/keyring"
 	"pgsync/internal/pkgmgr"
+	"pgsync/internal/storage"
 	"pgsync/internal/ui"
+	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -29,30 +34,67 @@ var rootCmd = &cobra.Command{
 
 			if response == "n" || response == "N" {
 				fmt.Println(ui.ErrorStyle.Render("\nCancelled. Please install PostgreSQL client tools manually."))
-				os.Exit(1)
+				exit(1)
 			}
 
 			fmt.Println(ui.PromptStyle.Render("\n→ Installing PostgreSQL client tools..."))
 			if err := pkgmgr.InstallPostgreSQL(); err != nil {
 				fmt.Println(ui.ErrorStyle.Render("✗ Installation failed: " + err.Error()))
-				os.Exit(1)
+				exit(1)
 			}
 			fmt.Println(ui.SuccessStyle.Render("✓ Installation complete\n"))
 		}
-		p := tea.NewProgram(ui.InitialModel(logo), tea.WithAltScreen())
+		cfg, store, err := loadConfig()
+		if err != nil {
+			fmt.Println(ui.ErrorStyle.Render("Error: " + err.Error()))
+			exit(1)
+		}
+
+		p := tea.NewProgram(ui.InitialModel(logo, cfg, store), tea.WithAltScreen())
 		if _, err := p.Run(); err != nil {
 			fmt.Println(ui.ErrorStyle.Render("Error: " + err.Error()))
-			os.Exit(1)
+			exit(1)
 		}
 	},
 }
 
 var logo string
 
+func loadConfig() (*config.Config, storage.Storage, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage: %w", err)
+	}
+	if _, err := keyring.New(cfg.Credentials.Keyring); err != nil {
+		return nil, nil, fmt.Errorf("credentials: %w", err)
+	}
+	return cfg, store, nil
+}
+
 func Execute(l string) {
 	logo = l
+	db.PgsyncVersion = rootCmd.Version
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		exit(130)
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		exit(1)
 	}
+	db.RemovePassfile()
+}
+
+// exit removes the password file written for keyring passwords, then exits.
+func exit(code int) {
+	db.RemovePassfile()
+	os.Exit(code)
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var sequencesCmd = &cobra.Command{
+	Use:   "sequences",
+	Short: "Inspect and synchronize sequence values",
+}
+
+var sequencesSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Advance target sequences to match the source and the data they own",
+	Long:  `Read last_value for every sequence on the source and max(column) for owned sequences on the target, then setval target sequences that are behind.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		source := connectionURL(cmd, "source")
+		target := connectionURL(cmd, "target")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cfg, _, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+		err = db.WithTunnel(source, sshFlags(cmd, "source"), cfg.Profiles, func(source string) error {
+			return db.WithTunnel(target, sshFlags(cmd, "target"), cfg.Profiles, func(target string) error {
+				return syncSequences(source, target, dryRun)
+			})
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+// syncSequences prints the sequences that are behind and, unless dryRun,
+// advances them.
+func syncSequences(source, target string, dryRun bool) error {
+	plan, err := db.PlanSequenceSync(source, target)
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	for _, s := range plan {
+		if s.Drift() <= 0 {
+			continue
+		}
+		drifted++
+		owner := ""
+		if s.OwnedBy != "" {
+			owner = fmt.Sprintf(" (owned by %s, max %d)", s.OwnedBy, s.TargetMax)
+		}
+		fmt.Printf("   %s: %d → %d (+%d)%s\n", s.Name, s.TargetValue, s.NewValue, s.Drift(), owner)
+	}
+
+	if drifted == 0 {
+		fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ All %d sequences are in sync", len(plan))))
+		return nil
+	}
+	if dryRun {
+		fmt.Println(ui.WarningStyle.Render(fmt.Sprintf("⚠ %d of %d sequences are behind (dry run, nothing changed)", drifted, len(plan))))
+		return nil
+	}
+
+	applied, err := db.ApplySequenceSync(target, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ Advanced %d sequences", len(applied))))
+	return nil
+}
+
+func init() {
+	sequencesSyncCmd.Flags().String("source", "", "source database URL")
+	addEndpointFlags(sequencesSyncCmd, "source")
+	sequencesSyncCmd.Flags().String("target", "", "target database URL")
+	addEndpointFlags(sequencesSyncCmd, "target")
+	sequencesSyncCmd.Flags().Bool("dry-run", false, "only report drift, do not call setval")
+	sequencesSyncCmd.MarkFlagRequired("source")
+	sequencesSyncCmd.MarkFlagRequired("target")
+	sequencesCmd.AddCommand(sequencesSyncCmd)
+	rootCmd.AddCommand(sequencesCmd)
+}
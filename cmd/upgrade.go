@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"pgsync/internal/db"
+	"pgsync/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var upgradeCheckCmd = &cobra.Command{
+	Use:   "upgrade-check",
+	Short: "Report objects that break when moving to a newer PostgreSQL major version",
+	Long:  `Inspect the source for removed types and features, changed defaults, extension versions not available on the target and deprecated syntax in functions and views, and list a remediation for each.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		source := connectionURL(cmd, "source")
+		target := connectionURL(cmd, "target")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		cfg, _, err := loadConfig()
+		if err != nil {
+			exitWithError(err)
+		}
+		var report *db.UpgradeReport
+		err = db.WithTunnel(source, sshFlags(cmd, "source"), cfg.Profiles, func(source string) error {
+			return db.WithTunnel(target, sshFlags(cmd, "target"), cfg.Profiles, func(target string) error {
+				report, err = db.CheckUpgrade(source, target)
+				return err
+			})
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				exitWithError(err)
+			}
+		} else {
+			printUpgradeReport(report)
+		}
+
+		if report.Count(db.StatusRed) > 0 {
+			exit(1)
+		}
+	},
+}
+
+func printUpgradeReport(report *db.UpgradeReport) {
+	fmt.Printf("   Source: %s\n", report.SourceVersion)
+	fmt.Printf("   Target: %s\n\n", report.TargetVersion)
+
+	if !report.IsUpgrade() {
+		fmt.Println(ui.SuccessStyle.Render("✓ Target is not a newer major version; nothing to check"))
+		return
+	}
+
+	for _, issue := range report.Issues {
+		title := fmt.Sprintf("[PG %d] %s", issue.Since, issue.Category)
+		if issue.Object != "" {
+			title += ": " + issue.Object
+		}
+		if issue.Severity == db.StatusRed {
+			fmt.Println(ui.ErrorStyle.Render("   ✗ " + title))
+		} else {
+			fmt.Println(ui.WarningStyle.Render("   ⚠ " + title))
+		}
+		fmt.Printf("     %s\n", issue.Problem)
+		fmt.Printf("     → %s\n\n", issue.Remediation)
+	}
+	for _, e := range report.Errors {
+		fmt.Println(ui.WarningStyle.Render("   ⚠ Not checked: " + e))
+	}
+
+	fmt.Println(report.Check().Message)
+}
+
+func init() {
+	upgradeCheckCmd.Flags().String("source", "", "source database URL")
+	addEndpointFlags(upgradeCheckCmd, "source")
+	upgradeCheckCmd.Flags().String("target", "", "target database URL")
+	addEndpointFlags(upgradeCheckCmd, "target")
+	upgradeCheckCmd.Flags().Bool("json", false, "print the report as JSON")
+	upgradeCheckCmd.MarkFlagRequired("source")
+	upgradeCheckCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(upgradeCheckCmd)
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type StorageConfig struct {
+	Type      string `json:"type"`
+	Dir       string `json:"dir,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+	PathStyle bool   `json:"path_style,omitempty"`
+}
+
+type Hook struct {
+	SQL   string `json:"sql,omitempty"`
+	On    string `json:"on,omitempty"`
+	Shell string `json:"shell,omitempty"`
+}
+
+type HooksConfig struct {
+	BeforeBackup  []Hook `json:"before_backup,omitempty"`
+	BeforeDump    []Hook `json:"before_dump,omitempty"`
+	BeforeRestore []Hook `json:"before_restore,omitempty"`
+	AfterRestore  []Hook `json:"after_restore,omitempty"`
+	OnFailure     []Hook `json:"on_failure,omitempty"`
+}
+
+// HistoryConfig controls how many past runs are kept. Zero MaxAgeDays keeps
+// runs regardless of age; negative MaxRuns keeps every run.
+type HistoryConfig struct {
+	MaxRuns    int `json:"max_runs,omitempty"`
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+// CredentialsConfig selects where remembered passwords are kept: auto,
+// secret-service, file or off.
+type CredentialsConfig struct {
+	Keyring string `json:"keyring,omitempty"`
+}
+
+// TLSConfig holds the libpq SSL settings for a connection. Settings written
+// in the URL itself take precedence.
+type TLSConfig struct {
+	SSLMode  string `json:"sslmode,omitempty"`
+	RootCert string `json:"sslrootcert,omitempty"`
+	Cert     string `json:"sslcert,omitempty"`
+	Key      string `json:"sslkey,omitempty"`
+}
+
+// EndpointsTLS holds the TLS defaults of the source and the target.
+type EndpointsTLS struct {
+	Source TLSConfig `json:"source"`
+	Target TLSConfig `json:"target"`
+}
+
+// SSHConfig reaches a database through SSH jump hosts, like ssh -J. Jump
+// lists [user@]host[:port] hops in order; the database host is dialled from
+// the last one. Key and KnownHosts default to the usual files in ~/.ssh, and
+// a running ssh-agent is used as well.
+type SSHConfig struct {
+	Jump       []string `json:"jump"`
+	Key        string   `json:"key,omitempty"`
+	KnownHosts string   `json:"known_hosts,omitempty"`
+}
+
+// Profile applies settings to the databases whose host matches one of
+// Hosts, shell patterns such as "*.prod.example.com". Profiles are tried in
+// order and the first match wins. Environment tags the databases, for
+// example "production" or "staging".
+type Profile struct {
+	Name        string     `json:"name"`
+	Hosts       []string   `json:"hosts"`
+	Environment string     `json:"environment,omitempty"`
+	Protected   bool       `json:"protected,omitempty"`
+	TLS         TLSConfig  `json:"tls"`
+	SSH         *SSHConfig `json:"ssh,omitempty"`
+}
+
+// Production reports whether the profile tags production databases.
+func (p *Profile) Production() bool {
+	return p != nil && strings.EqualFold(p.Environment, "production")
+}
+
+// IsProtected reports whether writing to the profile's databases needs the
+// database name typed to confirm and a backup. Production databases are
+// always protected.
+func (p *Profile) IsProtected() bool {
+	return p != nil && (p.Protected || p.Production())
+}
+
+// Label names the profile with its environment, such as
+// "prod (production, protected)".
+func (p *Profile) Label() string {
+	var tags []string
+	if p.Environment != "" {
+		tags = append(tags, p.Environment)
+	}
+	if p.IsProtected() {
+		tags = append(tags, "protected")
+	}
+	if len(tags) == 0 {
+		return p.Name
+	}
+	return p.Name + " (" + strings.Join(tags, ", ") + ")"
+}
+
+// ProfileFor returns the first profile matching host, or nil.
+func ProfileFor(profiles []Profile, host string) *Profile {
+	host = strings.ToLower(host)
+	for i := range profiles {
+		for _, pattern := range profiles[i].Hosts {
+			if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+				return &profiles[i]
+			}
+		}
+	}
+	return nil
+}
+
+type Config struct {
+	Storage     StorageConfig     `json:"storage"`
+	Hooks       HooksConfig       `json:"hooks"`
+	History     HistoryConfig     `json:"history"`
+	Credentials CredentialsConfig `json:"credentials"`
+	TLS         EndpointsTLS      `json:"tls"`
+	Profiles    []Profile         `json:"profiles,omitempty"`
+}
+
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".pgsync")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func Path() (string, error) {
+	if p := os.Getenv("PGSYNC_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+func Load() (*Config, error) {
+	cfg := &Config{
+		Storage: StorageConfig{Type: "local"},
+	}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+
+	applyEnv(cfg)
+
+	if err := cfg.Hooks.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.validateProfiles(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+func (h HooksConfig) validate() error {
+	points := map[string][]Hook{
+		"before_backup":  h.BeforeBackup,
+		"before_dump":    h.BeforeDump,
+		"before_restore": h.BeforeRestore,
+		"after_restore":  h.AfterRestore,
+		"on_failure":     h.OnFailure,
+	}
+	for name, hooks := range points {
+		for _, hook := range hooks {
+			if (hook.SQL == "") == (hook.Shell == "") {
+				return fmt.Errorf("hook in %s must set exactly one of sql or shell", name)
+			}
+			if hook.SQL != "" && hook.On != "source" && hook.On != "target" {
+				return fmt.Errorf("sql hook %s in %s must set on to source or target", hook.SQL, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateProfiles() error {
+	for _, p := range c.Profiles {
+		if p.Name == "" {
+			return fmt.Errorf("every profile needs a name")
+		}
+		if len(p.Hosts) == 0 {
+			return fmt.Errorf("profile %s has no hosts", p.Name)
+		}
+		for _, pattern := range p.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profile %s: bad host pattern %q", p.Name, pattern)
+			}
+		}
+		if p.SSH != nil && len(p.SSH.Jump) == 0 {
+			return fmt.Errorf("profile %s: ssh needs at least one jump host", p.Name)
+		}
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) {
+	setFromEnv(&cfg.Storage.Type, "PGSYNC_STORAGE")
+	setFromEnv(&cfg.Storage.Dir, "PGSYNC_BACKUP_DIR")
+	setFromEnv(&cfg.Storage.Endpoint, "PGSYNC_S3_ENDPOINT")
+	setFromEnv(&cfg.Storage.Region, "PGSYNC_S3_REGION")
+	setFromEnv(&cfg.Storage.Bucket, "PGSYNC_S3_BUCKET")
+	setFromEnv(&cfg.Storage.Prefix, "PGSYNC_S3_PREFIX")
+	setFromEnv(&cfg.Storage.AccessKey, "AWS_ACCESS_KEY_ID")
+	setFromEnv(&cfg.Storage.SecretKey, "AWS_SECRET_ACCESS_KEY")
+	setFromEnv(&cfg.Credentials.Keyring, "PGSYNC_KEYRING")
+	if os.Getenv("PGSYNC_S3_PATH_STYLE") == "true" {
+		cfg.Storage.PathStyle = true
+	}
+	if cfg.Storage.Type == "" {
+		cfg.Storage.Type = "local"
+	}
+}
+
+func setFromEnv(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
@@ -0,0 +1,293 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ActivityPolicy string
+
+const (
+	ActivityAbort     ActivityPolicy = "abort"
+	ActivityWait      ActivityPolicy = "wait"
+	ActivityTerminate ActivityPolicy = "terminate"
+)
+
+var ActivityPolicies = []ActivityPolicy{ActivityAbort, ActivityWait, ActivityTerminate}
+
+const defaultActivityTimeout = 5 * time.Minute
+
+// Session advisory lock key (classid, objid) held for the duration of a run.
+// Advisory locks are scoped to one database, so this serializes pgsync runs
+// per target database.
+const (
+	advisoryLockClass = 0x70677379 // "pgsy"
+	advisoryLockObj   = 0
+	lockAppPrefix     = "pgsync:"
+)
+
+type TargetSession struct {
+	PID         int
+	User        string
+	Application string
+	Client      string
+	State       string
+	XactAge     time.Duration
+	InXact      bool
+	Locks       int
+	Query       string
+}
+
+// Busy reports whether the session would block or be blocked by a restore.
+func (s TargetSession) Busy() bool {
+	return s.InXact || s.Locks > 0 || (s.State != "idle" && s.State != "")
+}
+
+func (s TargetSession) String() string {
+	who := s.User
+	if s.Application != "" {
+		who += "/" + s.Application
+	}
+	desc := fmt.Sprintf("pid %d (%s@%s) %s", s.PID, who, s.Client, s.State)
+	if s.InXact {
+		desc += fmt.Sprintf(", transaction open %s", s.XactAge.Round(time.Second))
+	}
+	if s.Locks > 0 {
+		desc += fmt.Sprintf(", %d locks", s.Locks)
+	}
+	return desc
+}
+
+const targetSessionsQuery = `SELECT a.pid, COALESCE(a.usename, ''), COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), 'local'), COALESCE(a.state, ''),
+	COALESCE(EXTRACT(epoch FROM now() - a.xact_start)::bigint, -1),
+	(SELECT count(*) FROM pg_locks l WHERE l.pid = a.pid AND l.locktype = 'relation' AND l.granted),
+	left(regexp_replace(COALESCE(a.query, ''), '\s+', ' ', 'g'), 100)
+FROM pg_stat_activity a
+WHERE a.datname = current_database() AND a.pid <> pg_backend_pid() AND a.backend_type = 'client backend'
+	AND COALESCE(a.application_name, '') NOT LIKE 'pgsync:%'
+ORDER BY a.xact_start NULLS LAST, a.pid;`
+
+func ListTargetSessions(target string) ([]TargetSession, error) {
+	rows, err := queryRows(target, targetSessionsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var sessions []TargetSession
+	for _, r := range rows {
+		if len(r) < 8 {
+			continue
+		}
+		pid, _ := strconv.Atoi(r[0])
+		age, _ := strconv.ParseInt(r[5], 10, 64)
+		locks, _ := strconv.Atoi(r[6])
+		sessions = append(sessions, TargetSession{
+			PID:         pid,
+			User:        r[1],
+			Application: r[2],
+			Client:      r[3],
+			State:       r[4],
+			XactAge:     time.Duration(age) * time.Second,
+			InXact:      age >= 0,
+			Locks:       locks,
+			Query:       r[7],
+		})
+	}
+	return sessions, nil
+}
+
+func busySessions(sessions []TargetSession) []TargetSession {
+	var busy []TargetSession
+	for _, s := range sessions {
+		if s.Busy() {
+			busy = append(busy, s)
+		}
+	}
+	return busy
+}
+
+func TerminateSessions(target string, sessions []TargetSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	pids := make([]string, len(sessions))
+	for i, s := range sessions {
+		pids[i] = strconv.Itoa(s.PID)
+	}
+	_, err := queryColumn(target, fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM unnest(ARRAY[%s]) AS pid;", strings.Join(pids, ", ")))
+	return err
+}
+
+func migrationLockHeld(target string) (bool, error) {
+	n, err := queryInt(target, fmt.Sprintf("SELECT count(*) FROM pg_locks WHERE locktype = 'advisory' AND classid = %d AND objid = %d AND granted AND database = (SELECT oid FROM pg_database WHERE datname = current_database());", advisoryLockClass, advisoryLockObj))
+	return n > 0, err
+}
+
+func activityCheck(target string) ([]TargetSession, CheckResult) {
+	const name = "Target Activity"
+	if held, err := migrationLockHeld(target); err == nil && held {
+		return nil, CheckResult{Name: name, Status: StatusRed, Message: "Another pgsync run is migrating into this database"}
+	}
+
+	sessions, err := ListTargetSessions(target)
+	if err != nil {
+		return nil, CheckResult{Name: name, Status: StatusYellow, Message: "Could not list sessions: " + err.Error()}
+	}
+	if len(sessions) == 0 {
+		return nil, CheckResult{Name: name, Status: StatusGreen, Message: "No other sessions connected"}
+	}
+
+	busy := busySessions(sessions)
+	if len(busy) == 0 {
+		return sessions, CheckResult{Name: name, Status: StatusGreen, Message: fmt.Sprintf("%d idle sessions connected, none holding locks", len(sessions))}
+	}
+	return sessions, CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("%d of %d sessions are active and would block the restore: %s", len(busy), len(sessions), sessionList(busy))}
+}
+
+func sessionList(sessions []TargetSession) string {
+	descs := make([]string, len(sessions))
+	for i, s := range sessions {
+		descs[i] = s.String()
+	}
+	return objectList(descs)
+}
+
+type targetLock struct {
+	stdin io.WriteCloser
+	cmd   *exec.Cmd
+	done  chan error
+}
+
+// acquireTargetLock takes the pgsync advisory lock on the target through a
+// psql session that stays open until releaseTargetLock. It only fails when
+// another run holds the lock; other problems (e.g. transaction poolers that
+// don't keep sessions) are reported as warnings.
+func (m *Migrator) acquireTargetLock() error {
+	app := lockAppPrefix + m.runID
+	cmd := exec.Command("psql", m.target, "-w", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	cmd.Env = append(os.Environ(), "PGAPPNAME="+app)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		m.lockWarning(err)
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	// Dividing by zero makes psql exit if the lock is already taken.
+	fmt.Fprintf(stdin, "SELECT 1 / pg_try_advisory_lock(%d, %d)::int;\n", advisoryLockClass, advisoryLockObj)
+
+	query := fmt.Sprintf("SELECT l.pid FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid WHERE l.locktype = 'advisory' AND l.classid = %d AND l.objid = %d AND l.granted AND a.application_name = %s;",
+		advisoryLockClass, advisoryLockObj, quoteLiteral(app))
+	deadline := time.Now().Add(cmdTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-done:
+			msg := strings.TrimSpace(stderr.String())
+			if strings.Contains(msg, "division by zero") {
+				return fmt.Errorf("another pgsync run is already migrating into this database")
+			}
+			if msg == "" {
+				msg = fmt.Sprintf("psql exited early: %v", err)
+			}
+			m.lockWarning(fmt.Errorf("%s", msg))
+			return nil
+		case <-time.After(200 * time.Millisecond):
+		}
+		if pids, err := queryColumn(m.target, query); err == nil && len(pids) > 0 {
+			m.lock = &targetLock{stdin: stdin, cmd: cmd, done: done}
+			m.writeLog("Holding migration lock on target (backend pid %s)", pids[0])
+			return nil
+		}
+	}
+
+	stdin.Close()
+	cmd.Process.Kill()
+	m.lockWarning(fmt.Errorf("timed out"))
+	return nil
+}
+
+func (m *Migrator) lockWarning(err error) {
+	m.writeLog("Could not take migration lock: %v", err)
+	m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Could not take migration lock on target (%v); concurrent runs are not prevented", err))
+}
+
+func (m *Migrator) releaseTargetLock() {
+	if m.lock == nil {
+		return
+	}
+	m.lock.stdin.Close()
+	select {
+	case <-m.lock.done:
+	case <-time.After(5 * time.Second):
+		m.lock.cmd.Process.Kill()
+	}
+	m.lock = nil
+	m.writeLog("Released migration lock")
+}
+
+// ensureTargetIdle applies the activity policy right before the target is
+// modified: abort if sessions are busy, wait for them to finish, or
+// terminate every other session on the target database.
+func (m *Migrator) ensureTargetIdle(pct float64, step string) error {
+	m.sendProgress(pct, step+": Checking target activity...", "SELECT ... FROM pg_stat_activity")
+	sessions, err := ListTargetSessions(m.target)
+	if err != nil {
+		m.writeLog("Activity check failed: %v", err)
+		m.stats.Warnings = append(m.stats.Warnings, "Could not check target activity: "+err.Error())
+		return nil
+	}
+
+	switch m.options.OnActivity {
+	case ActivityTerminate:
+		if len(sessions) == 0 {
+			return nil
+		}
+		m.sendProgress(pct, fmt.Sprintf("%s: Terminating %d sessions on target...", step, len(sessions)), "SELECT pg_terminate_backend(...)")
+		for _, s := range sessions {
+			m.writeLog("Terminating %s: %s", s, s.Query)
+		}
+		if err := TerminateSessions(m.target, sessions); err != nil {
+			return fmt.Errorf("failed to terminate sessions on target: %w", err)
+		}
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Terminated %d sessions on target: %s", len(sessions), sessionList(sessions)))
+		return nil
+
+	case ActivityWait:
+		timeout := m.options.ActivityTimeout
+		if timeout <= 0 {
+			timeout = defaultActivityTimeout
+		}
+		deadline := time.Now().Add(timeout)
+		for busy := busySessions(sessions); len(busy) > 0; busy = busySessions(sessions) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("target still busy after %s: %s", timeout, sessionList(busy))
+			}
+			m.sendProgress(pct, fmt.Sprintf("%s: Waiting for %d active sessions on target...", step, len(busy)), busy[0].String())
+			time.Sleep(2 * time.Second)
+			if sessions, err = ListTargetSessions(m.target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if busy := busySessions(sessions); len(busy) > 0 {
+		for _, s := range busy {
+			m.writeLog("Active session %s: %s", s, s.Query)
+		}
+		return fmt.Errorf("target has %d active sessions (wait or terminate them to continue): %s", len(busy), sessionList(busy))
+	}
+	return nil
+}
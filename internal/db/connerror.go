@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"strings"
+)
+
+// ConnErrorKind says why a connection failed.
+type ConnErrorKind string
+
+const (
+	ConnDNS     ConnErrorKind = "dns"
+	ConnRefused ConnErrorKind = "refused"
+	ConnAuth    ConnErrorKind = "auth"
+	ConnSSL     ConnErrorKind = "ssl"
+	ConnTimeout ConnErrorKind = "timeout"
+	ConnOther   ConnErrorKind = "other"
+)
+
+// connErrorPatterns maps libpq messages to kinds. They are tried in order:
+// a pg_hba.conf rejection mentioning SSL is a TLS problem, not a password
+// one.
+var connErrorPatterns = []struct {
+	kind     ConnErrorKind
+	patterns []string
+}{
+	{ConnDNS, []string{"could not translate host name", "name or service not known", "nodename nor servname", "temporary failure in name resolution", "no address associated"}},
+	{ConnTimeout, []string{"timeout expired", "timed out"}},
+	{ConnSSL, []string{"ssl", "certificate", "no encryption", "tls"}},
+	{ConnAuth, []string{"authentication failed", "no password supplied", "pg_hba.conf", "does not exist", "permission denied for database"}},
+	{ConnRefused, []string{"connection refused", "no such file or directory", "could not connect", "no response"}},
+}
+
+// Label describes the kind for a heading.
+func (k ConnErrorKind) Label() string {
+	switch k {
+	case ConnDNS:
+		return "host name not found"
+	case ConnRefused:
+		return "connection refused"
+	case ConnAuth:
+		return "authentication failed"
+	case ConnSSL:
+		return "TLS error"
+	case ConnTimeout:
+		return "connection timed out"
+	}
+	return "connection failed"
+}
+
+// Hint suggests what to check for the kind.
+func (k ConnErrorKind) Hint() string {
+	switch k {
+	case ConnDNS:
+		return "Check the host name in the URL and that it resolves from this machine."
+	case ConnRefused:
+		return "Check that the server is running and listening on this host and port, and that no firewall blocks it."
+	case ConnAuth:
+		return "Check the user, password and database name, and the pg_hba.conf rules for this client."
+	case ConnSSL:
+		return "Check sslmode and the certificate files (ctrl+t on the URL screen)."
+	case ConnTimeout:
+		return "The host did not answer in time. Check the network path: VPN, SSH tunnel, security groups."
+	}
+	return ""
+}
+
+// ConnError is a failed connection to the source or the target, with the
+// client tool's output.
+type ConnError struct {
+	Endpoint string
+	Kind     ConnErrorKind
+	Output   string
+}
+
+// NewConnError classifies err, the output of psql or pg_isready, as a
+// failed connection to endpoint ("source" or "target").
+func NewConnError(endpoint string, err error) *ConnError {
+	output := strings.TrimSpace(err.Error())
+	kind := ConnOther
+	lower := strings.ToLower(output)
+	for _, p := range connErrorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				kind = p.kind
+				break
+			}
+		}
+		if kind != ConnOther {
+			break
+		}
+	}
+	return &ConnError{Endpoint: endpoint, Kind: kind, Output: output}
+}
+
+func (e *ConnError) Error() string {
+	return e.Endpoint + " " + e.Kind.Label() + ": " + e.Output
+}
+
+// ConnErrors returns the connection failures in err, which may join several.
+func ConnErrors(err error) []*ConnError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var all []*ConnError
+		for _, e := range joined.Unwrap() {
+			all = append(all, ConnErrors(e)...)
+		}
+		return all
+	}
+	var ce *ConnError
+	if errors.As(err, &ce) {
+		return []*ConnError{ce}
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ConnInfo is a parsed libpq connection string. Hosts and ports are kept
+// apart so multi-host failover lists survive a round trip.
+type ConnInfo struct {
+	Hosts    []HostPort
+	DBName   string
+	User     string
+	Password string
+	Params   map[string]string
+}
+
+type HostPort struct {
+	Host string
+	Port string
+}
+
+// Keywords libpq accepts in a connection string or URL query.
+var connKeywords = map[string]bool{
+	"host": true, "hostaddr": true, "port": true, "dbname": true, "user": true, "password": true,
+	"passfile": true, "require_auth": true, "channel_binding": true, "connect_timeout": true,
+	"client_encoding": true, "options": true, "application_name": true, "fallback_application_name": true,
+	"keepalives": true, "keepalives_idle": true, "keepalives_interval": true, "keepalives_count": true,
+	"tcp_user_timeout": true, "replication": true, "gssencmode": true, "sslmode": true, "requiressl": true,
+	"sslnegotiation": true, "sslcompression": true, "sslcert": true, "sslkey": true, "sslkeylogfile": true,
+	"sslpassword": true, "sslcertmode": true, "sslrootcert": true, "sslcrl": true, "sslcrldir": true,
+	"sslsni": true, "requirepeer": true, "ssl_min_protocol_version": true, "ssl_max_protocol_version": true,
+	"krbsrvname": true, "gsslib": true, "gssdelegation": true, "service": true, "target_session_attrs": true,
+	"load_balance_hosts": true, "min_protocol_version": true, "max_protocol_version": true,
+	"oauth_issuer": true, "oauth_client_id": true, "oauth_client_secret": true, "oauth_scope": true,
+}
+
+var allowedValues = map[string][]string{
+	"sslmode":              {"disable", "allow", "prefer", "require", "verify-ca", "verify-full"},
+	"target_session_attrs": {"any", "read-write", "read-only", "primary", "standby", "prefer-standby"},
+	"load_balance_hosts":   {"disable", "random"},
+}
+
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9._-]*[A-Za-z0-9_])?$`)
+
+// ParseConnString parses a postgres:// or postgresql:// URL or a keyword/value
+// string such as "host=db dbname=app". A service named in it is merged in,
+// with the string's own settings taking precedence.
+func ParseConnString(s string) (*ConnInfo, error) {
+	s = strings.TrimSpace(s)
+	var params map[string]string
+	var err error
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("URL cannot be empty")
+	case strings.HasPrefix(s, "postgres://"), strings.HasPrefix(s, "postgresql://"):
+		params, err = parseConnURL(s)
+	case strings.Contains(s, "="):
+		params, err = parseKeywordValue(s)
+	default:
+		return nil, fmt.Errorf("please enter a PostgreSQL URL (postgres://...) or a connection string (host=... dbname=...)")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return connInfoFromParams(params)
+}
+
+// parseConnURL splits a connection URI into keywords. Unlike net/url it
+// accepts comma-separated host lists and percent-encoded socket paths.
+func parseConnURL(s string) (map[string]string, error) {
+	params := map[string]string{}
+	rest := s[strings.Index(s, "://")+3:]
+
+	query := ""
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	authority, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		authority, path = rest[:i], rest[i+1:]
+	}
+
+	if i := strings.IndexByte(authority, '@'); i >= 0 {
+		userinfo := authority[:i]
+		authority = authority[i+1:]
+		user, pass, hasPass := strings.Cut(userinfo, ":")
+		var err error
+		if params["user"], err = url.PathUnescape(user); err != nil {
+			return nil, fmt.Errorf("invalid user in URL: %w", err)
+		}
+		if params["user"] == "" {
+			delete(params, "user")
+		}
+		if hasPass {
+			if params["password"], err = url.PathUnescape(pass); err != nil {
+				return nil, fmt.Errorf("invalid password in URL: %w", err)
+			}
+		}
+	}
+
+	if authority != "" {
+		var hosts, ports []string
+		for _, hp := range strings.Split(authority, ",") {
+			host, port, err := splitHostPort(hp)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, host)
+			ports = append(ports, port)
+		}
+		params["host"] = strings.Join(hosts, ",")
+		if strings.Trim(strings.Join(ports, ""), ",") != "" {
+			params["port"] = strings.Join(ports, ",")
+		}
+	}
+
+	if path != "" {
+		dbname, err := url.PathUnescape(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database name in URL: %w", err)
+		}
+		params["dbname"] = dbname
+	}
+
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL parameters: %w", err)
+		}
+		for k, v := range values {
+			value := v[len(v)-1]
+			// libpq accepts the JDBC-style ssl=true.
+			if k == "ssl" && value == "true" {
+				k, value = "sslmode", "require"
+			}
+			params[k] = value
+		}
+	}
+	return params, nil
+}
+
+func splitHostPort(hp string) (string, string, error) {
+	hp, err := url.PathUnescape(hp)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid host %q: %w", hp, err)
+	}
+	if strings.HasPrefix(hp, "[") {
+		end := strings.IndexByte(hp, ']')
+		if end < 0 {
+			return "", "", fmt.Errorf("invalid host %q: missing ]", hp)
+		}
+		host, rest := hp[1:end], hp[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("invalid host %q", hp)
+		}
+		return host, strings.TrimPrefix(rest, ":"), nil
+	}
+	if strings.HasPrefix(hp, "/") {
+		return hp, "", nil
+	}
+	host, port, _ := strings.Cut(hp, ":")
+	return host, port, nil
+}
+
+// parseKeywordValue parses "key=value key='quoted value'" with libpq's
+// quoting rules.
+func parseKeywordValue(s string) (map[string]string, error) {
+	params := map[string]string{}
+	i := 0
+	skipSpace := func() {
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+	}
+	for {
+		skipSpace()
+		if i >= len(s) {
+			return params, nil
+		}
+		start := i
+		for i < len(s) && s[i] != '=' && !unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+		key := s[start:i]
+		skipSpace()
+		if i >= len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("missing \"=\" after %q in connection string", key)
+		}
+		i++
+		skipSpace()
+
+		var value strings.Builder
+		if i < len(s) && s[i] == '\'' {
+			i++
+			closed := false
+			for i < len(s) {
+				c := s[i]
+				i++
+				if c == '\\' && i < len(s) {
+					value.WriteByte(s[i])
+					i++
+				} else if c == '\'' {
+					closed = true
+					break
+				} else {
+					value.WriteByte(c)
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted value for %q in connection string", key)
+			}
+		} else {
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+		}
+		params[key] = value.String()
+	}
+}
+
+// connInfoFromParams merges the named service, if any, and validates the
+// connection keywords.
+func connInfoFromParams(params map[string]string) (*ConnInfo, error) {
+	if name := params["service"]; name != "" {
+		services, err := LoadServices()
+		if err != nil {
+			return nil, err
+		}
+		svc, ok := services[name]
+		if !ok {
+			return nil, fmt.Errorf("service %q not found in %s", name, strings.Join(ServiceFiles(), ", "))
+		}
+		merged := map[string]string{}
+		for k, v := range svc {
+			merged[k] = v
+		}
+		for k, v := range params {
+			merged[k] = v
+		}
+		delete(merged, "service")
+		params = merged
+	}
+
+	info := &ConnInfo{Params: map[string]string{}}
+	var hosts, ports []string
+	for k, v := range params {
+		if !connKeywords[k] {
+			return nil, fmt.Errorf("unknown connection parameter %q", k)
+		}
+		if allowed, ok := allowedValues[k]; ok && v != "" && !contains(allowed, v) {
+			return nil, fmt.Errorf("invalid %s %q (expected one of %s)", k, v, strings.Join(allowed, ", "))
+		}
+		switch k {
+		case "host":
+			hosts = strings.Split(v, ",")
+		case "port":
+			ports = strings.Split(v, ",")
+		case "dbname":
+			info.DBName = v
+		case "user":
+			info.User = v
+		case "password":
+			info.Password = v
+		default:
+			info.Params[k] = v
+		}
+	}
+	if v, ok := info.Params["connect_timeout"]; ok {
+		if _, err := strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid connect_timeout %q", v)
+		}
+	}
+
+	for _, h := range hosts {
+		if err := validateHost(h); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range ports {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port %q", p)
+		}
+	}
+	if len(ports) > 1 && len(ports) != len(hosts) {
+		return nil, fmt.Errorf("%d ports given for %d hosts", len(ports), len(hosts))
+	}
+	if len(info.DBName) > 63 {
+		return nil, fmt.Errorf("database name %q is longer than 63 bytes", info.DBName)
+	}
+
+	for i, h := range hosts {
+		hp := HostPort{Host: h}
+		switch {
+		case len(ports) == 1:
+			hp.Port = ports[0]
+		case len(ports) > 1:
+			hp.Port = ports[i]
+		}
+		info.Hosts = append(info.Hosts, hp)
+	}
+	if len(hosts) == 0 && len(ports) == 1 && ports[0] != "" {
+		info.Hosts = []HostPort{{Port: ports[0]}}
+	}
+	return info, nil
+}
+
+func validateHost(h string) error {
+	switch {
+	case h == "", strings.HasPrefix(h, "/"), hostnamePattern.MatchString(h), net.ParseIP(h) != nil:
+		return nil
+	}
+	return fmt.Errorf("invalid host %q", h)
+}
+
+// URL returns the connection as a postgres:// URL that pg_dump, pg_restore
+// and psql accept. Socket directories move to the host parameter.
+func (c *ConnInfo) URL() string {
+	var b strings.Builder
+	b.WriteString("postgres://")
+	if c.User != "" || c.Password != "" {
+		if c.Password != "" {
+			b.WriteString(url.UserPassword(c.User, c.Password).String())
+		} else {
+			b.WriteString(url.User(c.User).String())
+		}
+		b.WriteString("@")
+	}
+
+	q := url.Values{}
+	for k, v := range c.Params {
+		q.Set(k, v)
+	}
+	sockets := false
+	for _, hp := range c.Hosts {
+		if strings.HasPrefix(hp.Host, "/") {
+			sockets = true
+		}
+	}
+	if sockets {
+		var hosts, ports []string
+		for _, hp := range c.Hosts {
+			hosts = append(hosts, hp.Host)
+			ports = append(ports, hp.Port)
+		}
+		q.Set("host", strings.Join(hosts, ","))
+		if strings.Trim(strings.Join(ports, ","), ",") != "" {
+			q.Set("port", strings.Join(ports, ","))
+		}
+	} else {
+		for i, hp := range c.Hosts {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if strings.Contains(hp.Host, ":") {
+				b.WriteString("[" + hp.Host + "]")
+			} else {
+				b.WriteString(hp.Host)
+			}
+			if hp.Port != "" {
+				b.WriteString(":" + hp.Port)
+			}
+		}
+	}
+
+	b.WriteString("/")
+	b.WriteString(url.PathEscape(c.DBName))
+	if len(q) > 0 {
+		b.WriteString("?" + q.Encode())
+	}
+	return b.String()
+}
+
+// NormalizeURL parses any accepted connection string and returns it as a
+// postgres:// URL.
+func NormalizeURL(s string) (string, error) {
+	info, err := ParseConnString(s)
+	if err != nil {
+		return "", err
+	}
+	return info.URL(), nil
+}
+
+// connSecrets are the keywords RedactURL hides.
+var connSecrets = []string{"password", "sslpassword", "oauth_client_secret"}
+
+const redactedSecret = "xxxxx"
+
+// secretPattern finds secrets in strings that don't parse.
+var secretPattern = regexp.MustCompile(`(?i)\b(password|sslpassword|oauth_client_secret)\s*=\s*('(?:[^'\\]|\\.)*'|[^\s&]*)`)
+
+// RedactURL hides the passwords in a connection URL or keyword/value string,
+// as history and logs store it. The rest is kept as written, so redacting the
+// same connection always gives the same string.
+func RedactURL(connURL string) string {
+	return scrubSecrets(connURL, false)
+}
+
+// StripPassword removes the passwords from a connection URL or keyword/value
+// string, including the placeholders RedactURL leaves, so that the password
+// file, the keyring or the environment supply them instead.
+func StripPassword(connURL string) string {
+	return scrubSecrets(connURL, true)
+}
+
+// scrubSecrets masks the secrets in a connection string, or with strip set
+// drops them.
+func scrubSecrets(connURL string, strip bool) string {
+	s := strings.TrimSpace(connURL)
+	switch {
+	case strings.HasPrefix(s, "postgres://"), strings.HasPrefix(s, "postgresql://"):
+		return scrubConnURL(s, strip)
+	case strings.Contains(s, "="):
+		params, err := parseKeywordValue(s)
+		if err != nil {
+			return scrubPattern(s, strip)
+		}
+		if !maskSecrets(params, strip) {
+			return connURL
+		}
+		return keywordString(params)
+	}
+	return connURL
+}
+
+// scrubPattern masks or drops the secrets of a string that doesn't parse.
+func scrubPattern(s string, strip bool) string {
+	if !strip {
+		return secretPattern.ReplaceAllString(s, "${1}="+redactedSecret)
+	}
+	s = secretPattern.ReplaceAllString(s, "")
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "&&", "&")), " ")
+}
+
+// scrubConnURL masks or drops the password in the user info and any secret
+// query parameters of a connection URI.
+func scrubConnURL(s string, strip bool) string {
+	scheme, rest, _ := strings.Cut(s, "://")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+	authority, path, hasPath := strings.Cut(rest, "/")
+	if userinfo, hosts, ok := strings.Cut(authority, "@"); ok {
+		if user, _, hasPass := strings.Cut(userinfo, ":"); hasPass {
+			if strip {
+				authority = user + "@" + hosts
+			} else {
+				authority = user + ":" + redactedSecret + "@" + hosts
+			}
+		}
+	}
+
+	out := scheme + "://" + authority
+	if hasPath {
+		out += "/" + path
+	}
+	if hasQuery {
+		values, err := url.ParseQuery(query)
+		switch {
+		case err != nil:
+			query = strings.Trim(scrubPattern(query, strip), "&")
+		case maskSecretValues(values, strip):
+			query = values.Encode()
+		}
+		if query != "" || !strip {
+			out += "?" + query
+		}
+	}
+	return out
+}
+
+// maskSecrets masks, or with strip set deletes, the secrets in params and
+// reports whether there were any.
+func maskSecrets(params map[string]string, strip bool) bool {
+	found := false
+	for _, k := range connSecrets {
+		if _, ok := params[k]; ok {
+			if strip {
+				delete(params, k)
+			} else {
+				params[k] = redactedSecret
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+func maskSecretValues(values url.Values, strip bool) bool {
+	found := false
+	for _, k := range connSecrets {
+		if values.Has(k) {
+			if strip {
+				values.Del(k)
+			} else {
+				values.Set(k, redactedSecret)
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// keywordString formats params as a keyword/value connection string, quoting
+// values as libpq expects.
+func keywordString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		v := params[k]
+		if v == "" || strings.ContainsAny(v, " \t\n'\\") {
+			v = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+		}
+		parts[i] = k + "=" + v
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pgsync/internal/keyring"
+)
+
+// Passwords can come from places other than the URL. libpq reads ~/.pgpass,
+// pg_service.conf and the PG* variables itself when pg_dump, pg_restore and
+// psql connect; pgsync resolves them only to build URLs and to tell the user
+// where a password will come from. Passwords remembered in the keyring are
+// handed to libpq through a password file (see passfile.go).
+
+// ServiceFiles returns the pg_service.conf files libpq would read, user file
+// first.
+func ServiceFiles() []string {
+	var files []string
+	if f := os.Getenv("PGSERVICEFILE"); f != "" {
+		files = append(files, f)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".pg_service.conf"))
+	}
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "pg_service.conf"))
+	} else {
+		files = append(files, "/etc/pg_service.conf", "/etc/postgresql-common/pg_service.conf")
+	}
+	return files
+}
+
+// LoadServices reads the connection services from all service files. A
+// service in the user file hides one of the same name in a system file.
+func LoadServices() (map[string]map[string]string, error) {
+	services := map[string]map[string]string{}
+	for _, path := range ServiceFiles() {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseServiceFile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for name, params := range parsed {
+			if _, ok := services[name]; !ok {
+				services[name] = params
+			}
+		}
+	}
+	return services, nil
+}
+
+func parseServiceFile(f *os.File) (map[string]map[string]string, error) {
+	services := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = map[string]string{}
+			services[strings.TrimSpace(line[1:len(line)-1])] = current
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || current == nil {
+				return nil, fmt.Errorf("line %d: expected [service] or key=value", n)
+			}
+			current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return services, scanner.Err()
+}
+
+// ServiceNames lists the services available for selection, sorted.
+func ServiceNames() []string {
+	services, err := LoadServices()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServiceURL builds a URL from a pg_service.conf entry.
+func ServiceURL(name string) (string, error) {
+	info, err := connInfoFromParams(map[string]string{"service": name})
+	if err != nil {
+		return "", err
+	}
+	return info.URL(), nil
+}
+
+// EnvURL builds a URL from PGHOST, PGPORT, PGUSER, PGDATABASE and
+// PGSSLMODE, or returns "" when neither a host nor a database is set.
+// PGPASSWORD is left to libpq.
+func EnvURL() string {
+	params := map[string]string{}
+	for key, env := range map[string]string{
+		"host": "PGHOST", "port": "PGPORT", "user": "PGUSER", "dbname": "PGDATABASE", "sslmode": "PGSSLMODE",
+	} {
+		if v := os.Getenv(env); v != "" {
+			params[key] = v
+		}
+	}
+	if params["host"] == "" && params["dbname"] == "" {
+		return ""
+	}
+	info, err := connInfoFromParams(params)
+	if err != nil {
+		return ""
+	}
+	return info.URL()
+}
+
+// connParams returns the host, port, database and user of a connection
+// with the defaults libpq applies.
+func connParams(connURL string) (host, port, dbname, user string, ok bool) {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return "", "", "", "", false
+	}
+	primary := info.Primary()
+	host, port = primary.Host, primary.Port
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	user = info.User
+	if user == "" {
+		user = os.Getenv("PGUSER")
+	}
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	return host, port, info.database(), user, true
+}
+
+func hasPassword(connURL string) bool {
+	info, err := ParseConnString(connURL)
+	return err == nil && info.Password != ""
+}
+
+// PgpassPath returns the user's password file: PGPASSFILE as pgsync was
+// started with it, or ~/.pgpass.
+func PgpassPath() string {
+	if userPassfile != "" {
+		return userPassfile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// LookupPgpass finds the password libpq would take from the password file.
+// Like libpq, it ignores a file others can read.
+func LookupPgpass(host, port, dbname, user string) (string, bool) {
+	path := PgpassPath()
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0077 != 0 {
+		return "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	want := []string{host, port, dbname, user}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpass(line)
+		if len(fields) != 5 {
+			continue
+		}
+		match := true
+		for i, w := range want {
+			if fields[i] != "*" && fields[i] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return fields[4], true
+		}
+	}
+	return "", false
+}
+
+// splitPgpass splits a .pgpass line on colons, honouring \: and \\.
+func splitPgpass(line string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case c == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// CredentialKey names a connection in the keyring.
+func CredentialKey(connURL string) string {
+	host, port, dbname, user, _ := connParams(connURL)
+	return fmt.Sprintf("%s@%s:%s/%s", user, host, port, dbname)
+}
+
+// StorePassword remembers the password of a URL in the keyring.
+func StorePassword(connURL string, kr keyring.Keyring) error {
+	if kr == nil {
+		return fmt.Errorf("no keyring configured")
+	}
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return err
+	}
+	if info.Password == "" {
+		return fmt.Errorf("the URL has no password to remember")
+	}
+	return kr.Set(CredentialKey(connURL), info.Password)
+}
+
+// PasswordSource describes where the password for a URL will come from, or
+// returns "" when none is found and the server must allow login without one.
+func PasswordSource(connURL string, kr keyring.Keyring) string {
+	if hasPassword(connURL) {
+		return "URL"
+	}
+	host, port, dbname, user, ok := connParams(connURL)
+	if !ok {
+		return ""
+	}
+	if kr != nil {
+		if _, err := kr.Get(CredentialKey(connURL)); err == nil {
+			return kr.Name()
+		}
+	}
+	if os.Getenv("PGPASSWORD") != "" {
+		return "PGPASSWORD"
+	}
+	if _, ok := LookupPgpass(host, port, dbname, user); ok {
+		return PgpassPath()
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPgpass(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"db:5432:shop:app:s3cret", []string{"db", "5432", "shop", "app", "s3cret"}},
+		{"*:*:*:app:s3cret", []string{"*", "*", "*", "app", "s3cret"}},
+		{`db:5432:shop:app:pa\:ss`, []string{"db", "5432", "shop", "app", "pa:ss"}},
+		{`db:5432:shop:app:pa\\ss`, []string{"db", "5432", "shop", "app", `pa\ss`}},
+		{"db:5432:shop:app:pa:ss", []string{"db", "5432", "shop", "app", "pa:ss"}},
+		{`db:5432:my\:db:app:s3cret`, []string{"db", "5432", "my:db", "app", "s3cret"}},
+		{"db:5432:shop", []string{"db", "5432", "shop"}},
+	}
+	for _, tt := range tests {
+		if got := splitPgpass(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPgpass(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestPgpassLineRoundTrip(t *testing.T) {
+	fields := []string{"db", "*", `my:db`, `a\pp`, `s3:cr\et`}
+	line := pgpassLine(append([]string{}, fields...)...)
+	if got := splitPgpass(line); !reflect.DeepEqual(got, fields) {
+		t.Errorf("splitPgpass(pgpassLine(%q)) = %q", fields, got)
+	}
+}
+
+func TestLookupPgpass(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgpass")
+	content := "# comment\n" +
+		"db:5432:shop:app:exact\n" +
+		"db:*:*:app:anydb\n" +
+		"*:*:*:*:fallback\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved := userPassfile
+	userPassfile = path
+	t.Cleanup(func() { userPassfile = saved })
+
+	tests := []struct {
+		host, port, dbname, user string
+		want                     string
+	}{
+		{"db", "5432", "shop", "app", "exact"},
+		{"db", "6432", "shop", "app", "anydb"},
+		{"db", "5432", "other", "app", "anydb"},
+		{"other", "5432", "shop", "app", "fallback"},
+		{"db", "5432", "shop", "admin", "fallback"},
+	}
+	for _, tt := range tests {
+		got, ok := LookupPgpass(tt.host, tt.port, tt.dbname, tt.user)
+		if !ok || got != tt.want {
+			t.Errorf("LookupPgpass(%s, %s, %s, %s) = %q, %v, want %q", tt.host, tt.port, tt.dbname, tt.user, got, ok, tt.want)
+		}
+	}
+
+	// Like libpq, a file others can read is ignored.
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := LookupPgpass("db", "5432", "shop", "app"); ok {
+		t.Errorf("LookupPgpass with a readable file = %q, want nothing", got)
+	}
+}
+
+func TestParseServiceFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		{
+			name: "services",
+			content: "# pg_service.conf\n" +
+				"[prod]\n" +
+				"host=db.example.com\n" +
+				"port = 6432\n" +
+				"\n" +
+				"[ dev ]\n" +
+				"dbname=shop_dev\n",
+			want: map[string]map[string]string{
+				"prod": {"host": "db.example.com", "port": "6432"},
+				"dev":  {"dbname": "shop_dev"},
+			},
+		},
+		{name: "empty", content: "", want: map[string]map[string]string{}},
+		{name: "key outside a service", content: "host=db\n[prod]\n", wantErr: true},
+		{name: "line without =", content: "[prod]\nhost\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pg_service.conf")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := parseServiceFile(f)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseServiceFile succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseServiceFile = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadServices(t *testing.T) {
+	userFile := filepath.Join(t.TempDir(), "pg_service.conf")
+	sysDir := t.TempDir()
+	if err := os.WriteFile(userFile, []byte("[prod]\nhost=user-db\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sysDir, "pg_service.conf"), []byte("[prod]\nhost=system-db\n[dev]\nhost=dev-db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGSERVICEFILE", userFile)
+	t.Setenv("PGSYSCONFDIR", sysDir)
+
+	services, err := LoadServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"prod": {"host": "user-db"},
+		"dev":  {"host": "dev-db"},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("LoadServices = %v, want %v", services, want)
+	}
+	if names := ServiceNames(); !reflect.DeepEqual(names, []string{"dev", "prod"}) {
+		t.Errorf("ServiceNames = %q, want [dev prod]", names)
+	}
+}
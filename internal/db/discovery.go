@@ -1,18 +1,26 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
 func GetTables(url string) ([]string, error) {
-	query := "SELECT table_schema || '.' || table_name FROM information_schema.tables WHERE table_schema NOT IN ('information_schema', 'pg_catalog') ORDER BY table_schema, table_name;"
+	// Only tables hold data to copy: views are left out, and partitioned
+	// tables are listed once through their parent.
+	query := "SELECT n.nspname || '.' || c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND n.nspname NOT IN ('information_schema', 'pg_catalog') AND n.nspname NOT LIKE 'pg_toast%' AND n.nspname NOT LIKE 'pg_temp%' ORDER BY 1;"
 
-	cmd := exec.Command("psql", url, "-t", "-c", query)
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "psql", url, "-w", "-X", "-t", "-c", query)
 	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("failed to list tables: connection timed out after %s", cmdTimeout)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to list tables: %v (output: %s)", err, string(out))
+		return nil, fmt.Errorf("failed to list tables: %s (%v)", strings.TrimSpace(string(out)), err)
 	}
 
 	var tables []string
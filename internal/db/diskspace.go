@@ -0,0 +1,178 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pgsync/internal/pkgmgr"
+	"pgsync/internal/storage"
+)
+
+// pg_dump -Fc compresses table data; assume a conservative 2:1 ratio.
+const dumpCompressionRatio = 0.5
+
+type spaceNeed struct {
+	label string
+	path  string
+	bytes int64
+}
+
+func getDBSizeBytes(url string) (int64, error) {
+	return queryInt(url, "SELECT pg_database_size(current_database());")
+}
+
+func getTableDataBytes(url string) (int64, error) {
+	return queryInt(url, "SELECT COALESCE(sum(pg_table_size(c.oid)), 0)::bigint FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind IN ('r', 'p', 'm') AND n.nspname NOT IN ('information_schema', 'pg_catalog') AND n.nspname NOT LIKE 'pg_toast%';")
+}
+
+func queryInt(url, query string) (int64, error) {
+	values, err := queryColumn(url, query)
+	if err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("query returned no rows")
+	}
+	return strconv.ParseInt(values[0], 10, 64)
+}
+
+func localSpaceChecks(dumpBytes, backupBytes int64, store storage.Storage) []CheckResult {
+	if store != nil && !storage.IsLocal(store) {
+		return []CheckResult{{Name: "Local Disk", Status: StatusGreen, Message: "Dumps and backups stream to object storage"}}
+	}
+
+	backupDir := "."
+	if l, ok := store.(*storage.Local); ok {
+		backupDir = l.Dir()
+	}
+
+	needs := []spaceNeed{
+		{label: "dump", path: os.TempDir(), bytes: dumpBytes},
+		{label: "backup", path: backupDir, bytes: backupBytes},
+	}
+
+	type fsNeed struct {
+		paths  []string
+		labels []string
+		bytes  int64
+	}
+	var order []uint64
+	byDevice := make(map[uint64]*fsNeed)
+	var checks []CheckResult
+
+	for _, n := range needs {
+		if n.bytes <= 0 {
+			continue
+		}
+		dev, err := pkgmgr.DeviceID(existingParent(n.path))
+		if errors.Is(err, errors.ErrUnsupported) {
+			// The volume cannot report its free space; skip the check.
+			continue
+		}
+		if err != nil {
+			checks = append(checks, CheckResult{Name: "Disk Space (" + n.path + ")", Status: StatusYellow, Message: "Could not inspect filesystem: " + err.Error()})
+			continue
+		}
+		fs, ok := byDevice[dev]
+		if !ok {
+			fs = &fsNeed{}
+			byDevice[dev] = fs
+			order = append(order, dev)
+		}
+		fs.paths = append(fs.paths, n.path)
+		fs.labels = append(fs.labels, n.label)
+		fs.bytes += n.bytes
+	}
+
+	for _, dev := range order {
+		fs := byDevice[dev]
+		name := "Disk Space (" + fs.paths[0] + ")"
+		free, err := pkgmgr.FreeSpace(existingParent(fs.paths[0]))
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			checks = append(checks, CheckResult{Name: name, Status: StatusYellow, Message: "Could not read free space: " + err.Error()})
+			continue
+		}
+		checks = append(checks, spaceCheck(name, strings.Join(fs.labels, " + "), fs.bytes, int64(free)))
+	}
+
+	return checks
+}
+
+func targetSpaceCheck(target string, restoreBytes, targetBytes int64) CheckResult {
+	const name = "Target Disk"
+	if restoreBytes <= 0 {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not estimate restore size"}
+	}
+	if !isLocalHost(target) {
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Restore needs ~%s; free space on a remote server is not discoverable", FormatBytes(restoreBytes))}
+	}
+
+	dataDir, err := queryColumn(target, "SHOW data_directory;")
+	if err != nil || len(dataDir) == 0 {
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Restore needs ~%s; data_directory not readable (needs pg_read_all_settings)", FormatBytes(restoreBytes))}
+	}
+
+	free, err := pkgmgr.FreeSpace(existingParent(dataDir[0]))
+	if errors.Is(err, errors.ErrUnsupported) {
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Restore needs ~%s; the volume does not report its free space", FormatBytes(restoreBytes))}
+	}
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Restore needs ~%s; could not read free space of %s", FormatBytes(restoreBytes), dataDir[0])}
+	}
+
+	// --clean drops the existing objects first, so their space becomes available.
+	return spaceCheck(name, "restore", restoreBytes, int64(free)+targetBytes)
+}
+
+func spaceCheck(name, what string, need, free int64) CheckResult {
+	msg := fmt.Sprintf("%s needs ~%s, %s available", what, FormatBytes(need), FormatBytes(free))
+	switch {
+	case need > free:
+		return CheckResult{Name: name, Status: StatusRed, Message: "Won't fit: " + msg}
+	case float64(need) > float64(free)*0.8:
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Tight: " + msg}
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: msg}
+}
+
+func isLocalHost(connURL string) bool {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return false
+	}
+	host := info.Primary().Host
+	return host == "" || host == "localhost" || host == "127.0.0.1" || host == "::1" || strings.HasPrefix(host, "/")
+}
+
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -18,9 +19,9 @@ const (
 )
 
 type CheckResult struct {
-	Name    string
-	Status  CheckStatus
-	Message string
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
 }
 
 type EstimationResult struct {
@@ -29,157 +30,352 @@ type EstimationResult struct {
 	DbSize        string
 	TableCount    int
 	Checks        []CheckResult
+	Tools         ClientTools
+	Upgrade       *UpgradeReport
+	Prediction    DurationPrediction
+	Sessions      []TargetSession
+	SourcePooler  *PoolerInfo
+	TargetPooler  *PoolerInfo
+
+	SourceBytes     int64
+	SourceDataBytes int64
+	TargetBytes     int64
 }
 
 const cmdTimeout = 10 * time.Second
 
-func Estimate(source, target string) (*EstimationResult, error) {
+// Estimate runs the pre-flight checks for a migration of the given type; an
+// empty type means schema and data.
+func Estimate(source, target string, migrationType MigrationType, options MigrationOptions) (*EstimationResult, error) {
+	t, err := openTunnels(options, source, target)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	return estimate(source, target, migrationType, options, t)
+}
+
+// estimate runs the checks through the open tunnels. Heuristics based on the
+// host and port, and duration predictions, look at the URLs as given.
+func estimate(source, target string, migrationType MigrationType, options MigrationOptions, t tunnels) (*EstimationResult, error) {
+	if migrationType == "" {
+		migrationType = SchemaAndData
+	}
+	src, tgt := t.url(source), t.url(target)
+	options = options.WithProvider(target, source)
 	res := &EstimationResult{}
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	var errs []error
-
-	addError := func(err error) {
-		mu.Lock()
-		errs = append(errs, err)
-		mu.Unlock()
-	}
+	var srcErr, tgtErr error
 
+	// Asking for the version proves the connection and the credentials; psql
+	// reports DNS, TLS and authentication failures that pg_isready does not.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-
-		if err := CheckConnection(source); err != nil {
-			addError(fmt.Errorf("source unreachable: %w", err))
-			return
-		}
-
-		ver, err := getPGVersion(source)
+		ver, err := getPGVersion(src)
+		mu.Lock()
+		defer mu.Unlock()
 		if err != nil {
-			addError(fmt.Errorf("failed to get source version: %w", err))
+			srcErr = NewConnError("source", err)
 			return
 		}
-		mu.Lock()
 		res.SourceVersion = ver
-		mu.Unlock()
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-
-		if err := CheckConnection(target); err != nil {
-			addError(fmt.Errorf("target unreachable: %w", err))
-			return
-		}
-
-		ver, err := getPGVersion(target)
+		ver, err := getPGVersion(tgt)
+		mu.Lock()
+		defer mu.Unlock()
 		if err != nil {
-			addError(fmt.Errorf("failed to get target version: %w", err))
+			tgtErr = NewConnError("target", err)
 			return
 		}
-		mu.Lock()
 		res.TargetVersion = ver
-		mu.Unlock()
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		size, err := getDBSize(source)
+		size, err := getDBSize(src)
 		mu.Lock()
 		defer mu.Unlock()
-		if err != nil {
-			res.Checks = append(res.Checks, CheckResult{Name: "Disk Space", Status: StatusYellow, Message: "Could not estimate size"})
-		} else {
+		if err == nil {
 			res.DbSize = size
-			res.Checks = append(res.Checks, CheckResult{Name: "Estimated Size", Status: StatusGreen, Message: size})
 		}
+		res.Checks = append(res.Checks, sizeCheck(size, err))
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		count, err := getTableCount(source)
+		count, err := getTableCount(src)
 		mu.Lock()
 		defer mu.Unlock()
-		if err != nil {
-			res.Checks = append(res.Checks, CheckResult{Name: "Table Count", Status: StatusYellow, Message: "Unknown"})
-		} else {
+		if err == nil {
 			res.TableCount = count
-			res.Checks = append(res.Checks, CheckResult{Name: "Objects", Status: StatusGreen, Message: fmt.Sprintf("%d tables", count)})
 		}
+		res.Checks = append(res.Checks, tableCountCheck(count, err))
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		exts, err := getExtensions(source)
+		exts, err := getExtensions(src)
 		var tgtExts []string
 		if err == nil {
-			tgtExts, err = getExtensions(target)
+			tgtExts, err = getExtensions(tgt)
 		}
 
 		mu.Lock()
 		defer mu.Unlock()
+		res.Checks = append(res.Checks, extensionsCheck(exts, tgtExts, err))
+	}()
 
-		if err == nil {
-			missing := []string{}
-			for _, srcExt := range exts {
-				found := false
-				for _, tgtExt := range tgtExts {
-					if srcExt == tgtExt {
-						found = true
-						break
-					}
-				}
-				if !found {
-					missing = append(missing, srcExt)
-				}
-			}
-			if len(missing) > 0 {
-				res.Checks = append(res.Checks, CheckResult{Name: "Extensions", Status: StatusRed, Message: fmt.Sprintf("Missing on target: %s", strings.Join(missing, ", "))})
-			} else {
-				res.Checks = append(res.Checks, CheckResult{Name: "Extensions", Status: StatusGreen, Message: "All extensions present"})
-			}
-		} else {
-			res.Checks = append(res.Checks, CheckResult{Name: "Extensions", Status: StatusYellow, Message: "Could not check extensions"})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		srcBytes, srcErr := getDBSizeBytes(src)
+		dataBytes, dataErr := getTableDataBytes(src)
+		tgtBytes, tgtErr := getDBSizeBytes(tgt)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if srcErr == nil {
+			res.SourceBytes = srcBytes
+		}
+		if dataErr == nil {
+			res.SourceDataBytes = dataBytes
 		}
+		if tgtErr == nil {
+			res.TargetBytes = tgtBytes
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sessions, check := activityCheck(tgt)
+		mu.Lock()
+		defer mu.Unlock()
+		res.Sessions = sessions
+		res.Checks = append(res.Checks, check)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checks := []CheckResult{tlsCheck("Source", src, options.Profiles), tlsCheck("Target", tgt, options.Profiles)}
+		mu.Lock()
+		defer mu.Unlock()
+		res.Checks = append(res.Checks, checks...)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checks := localeChecks(src, tgt)
+		checks = append(checks, permissionChecks(src, tgt, migrationType, options)...)
+		mu.Lock()
+		defer mu.Unlock()
+		res.Checks = append(res.Checks, checks...)
 	}()
 
 	wg.Wait()
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+	// The checks that ran are returned with the error, so the ones that
+	// passed can still be shown.
+	if err := errors.Join(srcErr, tgtErr); err != nil {
+		return res, err
 	}
 
-	verCheck := CheckResult{Name: "Postgres Compatibility", Status: StatusGreen, Message: "Versions are compatible"}
-	if res.SourceVersion != res.TargetVersion {
-		verCheck.Status = StatusYellow
-		verCheck.Message = fmt.Sprintf("Version mismatch: %s -> %s", res.SourceVersion, res.TargetVersion)
+	res.Checks = append(res.Checks, res.spaceChecks(tgt, options)...)
+	res.Checks = append(res.Checks, versionCheck(res.SourceVersion, res.TargetVersion))
+
+	tools, toolsCheck := SelectClientTools(DetectClientTools(), majorVersion(res.SourceVersion), majorVersion(res.TargetVersion))
+	res.Tools = tools
+	res.Checks = append(res.Checks, toolsCheck)
+
+	if majorVersion(res.TargetVersion) > majorVersion(res.SourceVersion) {
+		res.Upgrade = checkUpgrade(src, tgt, res.SourceVersion, res.TargetVersion)
+		res.Checks = append(res.Checks, res.Upgrade.Check())
 	}
-	res.Checks = append(res.Checks, verCheck)
 
-	if strings.Contains(source, ":6543") || strings.Contains(target, ":6543") {
-		res.Checks = append(res.Checks, CheckResult{
-			Name:    "Connection Mode",
-			Status:  StatusYellow,
-			Message: "Port 6543 detected (Supabase Pooler). Use 5432 if possible.",
-		})
+	var srcPooler, tgtPooler CheckResult
+	res.SourcePooler, srcPooler = poolerCheck("Source", source, src)
+	res.TargetPooler, tgtPooler = poolerCheck("Target", target, tgt)
+	res.Checks = append(res.Checks, srcPooler, tgtPooler)
+	res.Checks = append(res.Checks, providerCheck(options, target, source))
+	for _, e := range [][2]string{{"Source", source}, {"Target", target}} {
+		if check, ok := t.check(e[0], e[1]); ok {
+			res.Checks = append(res.Checks, check)
+		}
 	}
+	if check, ok := environmentCheck(target, options); ok {
+		res.Checks = append(res.Checks, check)
+	}
+
+	res.Prediction = PredictDuration(source, target, migrationType, res.SourceDataBytes, options.ParallelJobs)
+	res.Checks = append(res.Checks, durationCheck(res.Prediction))
 
 	return res, nil
 }
 
+func EstimateFromManifest(manifest *Manifest, target string, options MigrationOptions) (*EstimationResult, error) {
+	t, err := openTunnels(options, "", target)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	options = options.WithProvider(target)
+	tgt := t.url(target)
+	res := &EstimationResult{
+		SourceVersion: manifest.SourceVersion,
+		DbSize:        manifest.DbSize,
+		TableCount:    len(manifest.Tables),
+		SourceBytes:   manifest.DbSizeBytes,
+	}
+
+	ver, err := getPGVersion(tgt)
+	if err != nil {
+		return nil, NewConnError("target", err)
+	}
+	res.TargetVersion = ver
+
+	var sizeErr error
+	if manifest.DbSize == "" {
+		sizeErr = fmt.Errorf("size missing from manifest")
+	}
+	res.Checks = append(res.Checks, sizeCheck(manifest.DbSize, sizeErr))
+	res.Checks = append(res.Checks, tableCountCheck(len(manifest.Tables), nil))
+
+	tgtExts, err := getExtensions(tgt)
+	res.Checks = append(res.Checks, extensionsCheck(manifest.Extensions, tgtExts, err))
+	res.Checks = append(res.Checks, manifestLocaleChecks(manifest.Locale, tgt)...)
+	res.Checks = append(res.Checks, targetPermissionsCheck(tgt, options.FilterTables(manifest.Tables), manifest.MigrationType == DataOnly, options.LoadStrategy))
+
+	sessions, activity := activityCheck(tgt)
+	res.Sessions = sessions
+	res.Checks = append(res.Checks, activity)
+
+	if tgtBytes, err := getDBSizeBytes(tgt); err == nil {
+		res.TargetBytes = tgtBytes
+	}
+	var backupBytes int64
+	if options.AutoBackup {
+		backupBytes = int64(float64(res.TargetBytes) * dumpCompressionRatio)
+	}
+	res.Checks = append(res.Checks, localSpaceChecks(manifest.DumpSize, backupBytes, options.Storage)...)
+	res.Checks = append(res.Checks, targetSpaceCheck(tgt, res.SourceBytes, res.TargetBytes))
+
+	res.Checks = append(res.Checks, versionCheck(res.SourceVersion, res.TargetVersion))
+
+	tools, toolsCheck := SelectClientTools(DetectClientTools(), majorVersion(manifest.PgDumpVersion), majorVersion(res.TargetVersion))
+	res.Tools = tools
+	res.Checks = append(res.Checks, toolsCheck)
+
+	var tgtPooler CheckResult
+	res.TargetPooler, tgtPooler = poolerCheck("Target", target, tgt)
+	res.Checks = append(res.Checks, tgtPooler)
+	res.Checks = append(res.Checks, providerCheck(options, target))
+	res.Checks = append(res.Checks, tlsCheck("Target", tgt, options.Profiles))
+	if check, ok := t.check("Target", target); ok {
+		res.Checks = append(res.Checks, check)
+	}
+	if check, ok := environmentCheck(target, options); ok {
+		res.Checks = append(res.Checks, check)
+	}
+
+	return res, nil
+}
+
+func (r *EstimationResult) spaceChecks(target string, options MigrationOptions) []CheckResult {
+	dumpBytes := int64(float64(r.SourceDataBytes) * dumpCompressionRatio)
+	var backupBytes int64
+	if options.AutoBackup {
+		backupBytes = int64(float64(r.TargetBytes) * dumpCompressionRatio)
+	}
+
+	checks := localSpaceChecks(dumpBytes, backupBytes, options.Storage)
+	return append(checks, targetSpaceCheck(target, r.SourceBytes, r.TargetBytes))
+}
+
+func (r *EstimationResult) HasBlockers() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusRed {
+			return true
+		}
+	}
+	return false
+}
+
+func sizeCheck(size string, err error) CheckResult {
+	if err != nil {
+		return CheckResult{Name: "Disk Space", Status: StatusYellow, Message: "Could not estimate size"}
+	}
+	return CheckResult{Name: "Estimated Size", Status: StatusGreen, Message: size}
+}
+
+func tableCountCheck(count int, err error) CheckResult {
+	if err != nil {
+		return CheckResult{Name: "Table Count", Status: StatusYellow, Message: "Unknown"}
+	}
+	return CheckResult{Name: "Objects", Status: StatusGreen, Message: fmt.Sprintf("%d tables", count)}
+}
+
+func extensionsCheck(srcExts, tgtExts []string, err error) CheckResult {
+	if err != nil {
+		return CheckResult{Name: "Extensions", Status: StatusYellow, Message: "Could not check extensions"}
+	}
+
+	missing := []string{}
+	for _, srcExt := range srcExts {
+		found := false
+		for _, tgtExt := range tgtExts {
+			if srcExt == tgtExt {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, srcExt)
+		}
+	}
+	if len(missing) > 0 {
+		return CheckResult{Name: "Extensions", Status: StatusRed, Message: fmt.Sprintf("Missing on target: %s", strings.Join(missing, ", "))}
+	}
+	return CheckResult{Name: "Extensions", Status: StatusGreen, Message: "All extensions present"}
+}
+
+func versionCheck(sourceVersion, targetVersion string) CheckResult {
+	verCheck := CheckResult{Name: "Postgres Compatibility", Status: StatusGreen, Message: "Versions are compatible"}
+	sourceMajor, targetMajor := majorVersion(sourceVersion), majorVersion(targetVersion)
+	switch {
+	case sourceMajor > targetMajor:
+		verCheck.Status = StatusYellow
+		verCheck.Message = fmt.Sprintf("Downgrade %s -> %s: objects using newer features may fail to restore", sourceVersion, targetVersion)
+	case sourceMajor < targetMajor:
+		verCheck.Message = fmt.Sprintf("Major upgrade %s -> %s", sourceVersion, targetVersion)
+	case sourceVersion != targetVersion:
+		verCheck.Message = fmt.Sprintf("Same major version (%s -> %s)", sourceVersion, targetVersion)
+	}
+	return verCheck
+}
+
 func getPGVersion(url string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "psql", url, "-w", "-t", "-c", "SHOW server_version;")
+	cmd := exec.CommandContext(ctx, "psql", url, "-w", "-X", "-t", "-c", "SHOW server_version;")
 	out, err := cmd.CombinedOutput()
 	if ctx.Err() == context.DeadlineExceeded {
-		return "", fmt.Errorf("connection timed out")
+		return "", fmt.Errorf("connection timed out after %s", cmdTimeout)
 	}
 	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
 		return "", err
 	}
 	return strings.TrimSpace(string(out)), nil
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+
+	"pgsync/internal/config"
+)
+
+// TargetProfile returns the profile tagging the host of a connection, or nil
+// when no profile matches.
+func TargetProfile(connURL string, profiles []config.Profile) *config.Profile {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return nil
+	}
+	return config.ProfileFor(profiles, info.Primary().Host)
+}
+
+// DatabaseName returns the database a connection opens, with libpq's
+// defaults applied. Protected targets are confirmed by typing it.
+func DatabaseName(connURL string) string {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return ""
+	}
+	return info.database()
+}
+
+// CheckProtectedTarget refuses to write to a protected target without a
+// safety backup.
+func CheckProtectedTarget(target string, options MigrationOptions) error {
+	p := TargetProfile(target, options.Profiles)
+	if p.IsProtected() && !options.AutoBackup {
+		return fmt.Errorf("target %s is protected by profile %s and cannot be written without a safety backup", DatabaseName(target), p.Label())
+	}
+	return nil
+}
+
+// environmentCheck reports the environment a profile tags the target with,
+// or false when no profile matches.
+func environmentCheck(target string, options MigrationOptions) (CheckResult, bool) {
+	const name = "Target Environment"
+	p := TargetProfile(target, options.Profiles)
+	if p == nil {
+		return CheckResult{}, false
+	}
+	if err := CheckProtectedTarget(target, options); err != nil {
+		return CheckResult{Name: name, Status: StatusRed, Message: err.Error()}, true
+	}
+	if p.IsProtected() {
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Profile %s: the database name must be typed to confirm", p.Label())}, true
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: "Profile " + p.Label()}, true
+}
@@ -1,11 +1,18 @@
 package db
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"pgsync/internal/config"
 )
 
 type MigrationStatus string
@@ -15,7 +22,11 @@ const (
 	StatusFailed  MigrationStatus = "failed"
 )
 
+// PgsyncVersion is recorded with each run; cmd sets it at startup.
+var PgsyncVersion = "dev"
+
 type MigrationRecord struct {
+	ID            string          `json:"id,omitempty"`
 	Timestamp     time.Time       `json:"timestamp"`
 	Source        string          `json:"source"`
 	Target        string          `json:"target"`
@@ -23,68 +34,373 @@ type MigrationRecord struct {
 	Status        MigrationStatus `json:"status"`
 	Duration      string          `json:"duration"`
 	Error         string          `json:"error,omitempty"`
+	SourceBytes   int64           `json:"source_bytes,omitempty"`
+	BytesDumped   int64           `json:"bytes_dumped,omitempty"`
+	TableCount    int             `json:"table_count,omitempty"`
+	Phases        []PhaseTiming   `json:"phases,omitempty"`
+
+	Options         *RecordedOptions  `json:"options,omitempty"`
+	SelectedTables  []string          `json:"selected_tables,omitempty"`
+	Warnings        []string          `json:"warnings,omitempty"`
+	BackupPath      string            `json:"backup_path,omitempty"`
+	BackupKey       string            `json:"backup_key,omitempty"`
+	LogPath         string            `json:"log_path,omitempty"`
+	TableResults    []TableLoadResult `json:"table_results,omitempty"`
+	SequenceDrift   []SequenceSync    `json:"sequence_drift,omitempty"`
+	DidRollback     bool              `json:"did_rollback,omitempty"`
+	RollbackSuccess bool              `json:"rollback_success,omitempty"`
+	PgsyncVersion   string            `json:"pgsync_version,omitempty"`
+	PgDumpVersion   string            `json:"pg_dump_version,omitempty"`
 }
 
-func getHistoryPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	dir := filepath.Join(home, ".pgsync")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+// RecordedOptions are the migration options kept in history. Storage
+// credentials and hooks are left out.
+type RecordedOptions struct {
+	ParallelJobs    int                     `json:"parallel_jobs,omitempty"`
+	AutoBackup      bool                    `json:"auto_backup"`
+	LoadStrategy    LoadStrategy            `json:"load_strategy,omitempty"`
+	TableStrategies map[string]LoadStrategy `json:"table_strategies,omitempty"`
+	TruncateCascade bool                    `json:"truncate_cascade,omitempty"`
+	DisableTriggers bool                    `json:"disable_triggers,omitempty"`
+	SyncSequences   bool                    `json:"sync_sequences,omitempty"`
+	Analyze         AnalyzeMode             `json:"analyze,omitempty"`
+	RefreshMatViews bool                    `json:"refresh_matviews,omitempty"`
+	OnActivity      ActivityPolicy          `json:"on_activity,omitempty"`
+	Provider        string                  `json:"provider,omitempty"`
+	ExcludeSchemas  []string                `json:"exclude_schemas,omitempty"`
+	SkipExtensions  []string                `json:"skip_extensions,omitempty"`
+}
+
+func recordOptions(o MigrationOptions) *RecordedOptions {
+	return &RecordedOptions{
+		ParallelJobs:    o.ParallelJobs,
+		AutoBackup:      o.AutoBackup,
+		LoadStrategy:    o.LoadStrategy,
+		TableStrategies: o.TableStrategies,
+		TruncateCascade: o.TruncateCascade,
+		DisableTriggers: o.DisableTriggers,
+		SyncSequences:   o.SyncSequences,
+		Analyze:         o.Analyze,
+		RefreshMatViews: o.RefreshMatViews,
+		OnActivity:      o.OnActivity,
+		Provider:        o.Provider,
+		ExcludeSchemas:  o.ExcludeSchemas,
+		SkipExtensions:  o.SkipExtensions,
 	}
-	return filepath.Join(dir, "history.json"), nil
 }
 
-func SaveHistory(record MigrationRecord) error {
-	path, err := getHistoryPath()
-	if err != nil {
-		return err
+// Apply copies the recorded options onto o, for re-running a past migration.
+func (r *RecordedOptions) Apply(o *MigrationOptions) {
+	o.ParallelJobs = r.ParallelJobs
+	o.AutoBackup = r.AutoBackup
+	o.LoadStrategy = r.LoadStrategy
+	o.TableStrategies = make(map[string]LoadStrategy, len(r.TableStrategies))
+	for t, s := range r.TableStrategies {
+		o.TableStrategies[t] = s
 	}
+	o.TruncateCascade = r.TruncateCascade
+	o.DisableTriggers = r.DisableTriggers
+	o.SyncSequences = r.SyncSequences
+	o.Analyze = r.Analyze
+	o.RefreshMatViews = r.RefreshMatViews
+	o.OnActivity = r.OnActivity
+	o.Provider = r.Provider
+	o.ExcludeSchemas = r.ExcludeSchemas
+	o.SkipExtensions = r.SkipExtensions
+}
 
-	var history []MigrationRecord
-	data, err := os.ReadFile(path)
-	if err == nil {
-		_ = json.Unmarshal(data, &history)
+func (r MigrationRecord) phase(name string) time.Duration {
+	var d time.Duration
+	for _, p := range r.Phases {
+		if p.Name == name {
+			d += p.Duration
+		}
 	}
+	return d
+}
 
-	history = append([]MigrationRecord{record}, history...)
+const (
+	historyFile       = "history.jsonl"
+	historyLockFile   = "history.lock"
+	legacyHistoryFile = "history.json"
+	defaultMaxRuns    = 500
+)
 
-	if len(history) > 50 {
-		history = history[:50]
+// withHistoryLock runs fn while holding a lock on the history lock file, so
+// concurrent pgsync processes don't interleave or lose records. Readers share
+// the lock; writers hold it exclusively.
+func withHistoryLock(exclusive bool, fn func(dir string) error) error {
+	dir, err := config.Dir()
+	if err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, historyLockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock, exclusive); err != nil {
+		return fmt.Errorf("failed to lock history: %w", err)
 	}
+	defer unlockFile(lock)
+	return fn(dir)
+}
 
-	newData, err := json.MarshalIndent(history, "", "  ")
+// SaveHistory appends a record to the history file and applies the
+// retention settings.
+func SaveHistory(record MigrationRecord, retention config.HistoryConfig) error {
+	line, err := json.Marshal(record)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, newData, 0644)
+	return withHistoryLock(true, func(dir string) error {
+		if err := migrateLegacyHistory(dir); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, historyFile), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		// A run that crashed mid-write leaves a partial last line; start on a
+		// fresh one so this record isn't glued to it.
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			last := make([]byte, 1)
+			if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+				line = append([]byte{'\n'}, line...)
+			}
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return pruneHistory(dir, retention)
+	})
 }
 
 func LoadHistory() ([]MigrationRecord, error) {
-	path, err := getHistoryPath()
+	var history []MigrationRecord
+	err := withHistoryLock(false, func(dir string) error {
+		var err error
+		history, err = readHistory(dir)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(path)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.After(history[j].Timestamp)
+	})
+	return history, nil
+}
+
+// readHistory returns the records oldest first. Lines that don't parse, such
+// as one cut short by a crash, are skipped. Before the first save the legacy
+// history.json is read instead.
+func readHistory(dir string) ([]MigrationRecord, error) {
+	f, err := os.Open(filepath.Join(dir, historyFile))
+	if os.IsNotExist(err) {
+		return readLegacyHistory(dir)
+	}
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []MigrationRecord{}, nil
-		}
 		return nil, err
 	}
+	defer f.Close()
+
+	history := []MigrationRecord{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec MigrationRecord
+			if json.Unmarshal(line, &rec) == nil {
+				history = append(history, rec)
+			}
+		}
+		if err == io.EOF {
+			return history, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
 
+func readLegacyHistory(dir string) ([]MigrationRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, legacyHistoryFile))
+	if os.IsNotExist(err) {
+		return []MigrationRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	var history []MigrationRecord
 	if err := json.Unmarshal(data, &history); err != nil {
 		return nil, err
 	}
-
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].Timestamp.After(history[j].Timestamp)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
 	})
-
 	return history, nil
 }
+
+// migrateLegacyHistory converts history.json to the JSONL file once.
+func migrateLegacyHistory(dir string) error {
+	legacy := filepath.Join(dir, legacyHistoryFile)
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, historyFile)); err == nil {
+		return nil
+	}
+	history, err := readLegacyHistory(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", legacy, err)
+	}
+	if err := writeHistory(dir, history); err != nil {
+		return err
+	}
+	return os.Rename(legacy, legacy+".migrated")
+}
+
+// pruneHistory drops records beyond the retention limits and deletes their
+// log files. The file is only rewritten when something is dropped.
+func pruneHistory(dir string, retention config.HistoryConfig) error {
+	maxRuns := retention.MaxRuns
+	if maxRuns == 0 {
+		maxRuns = defaultMaxRuns
+	}
+	history, err := readHistory(dir)
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if retention.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -retention.MaxAgeDays)
+	}
+	keepFrom := 0
+	if maxRuns > 0 && len(history) > maxRuns {
+		keepFrom = len(history) - maxRuns
+	}
+
+	var kept, dropped []MigrationRecord
+	for i, rec := range history {
+		if i < keepFrom || rec.Timestamp.Before(cutoff) {
+			dropped = append(dropped, rec)
+		} else {
+			kept = append(kept, rec)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	if err := writeHistory(dir, kept); err != nil {
+		return err
+	}
+
+	logs := logDir(dir)
+	for _, rec := range dropped {
+		if rec.LogPath != "" && strings.HasPrefix(rec.LogPath, logs+string(os.PathSeparator)) {
+			os.Remove(rec.LogPath)
+		}
+	}
+	return nil
+}
+
+// writeHistory replaces the history file atomically.
+func writeHistory(dir string, history []MigrationRecord) error {
+	tmp, err := os.CreateTemp(dir, historyFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, rec := range history {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, historyFile))
+}
+
+func logDir(dir string) string {
+	return filepath.Join(dir, "logs")
+}
+
+// createRunLog opens the log file for a run under ~/.pgsync/logs, falling
+// back to the temp directory.
+func createRunLog(runID string) (*os.File, error) {
+	if dir, err := config.Dir(); err == nil {
+		logs := logDir(dir)
+		if err := os.MkdirAll(logs, 0700); err == nil {
+			name := fmt.Sprintf("%s_%s.log", time.Now().Format("20060102_150405"), runID)
+			if f, err := os.OpenFile(filepath.Join(logs, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return os.CreateTemp("", "pgsync_migration_*.log")
+}
+
+// HistoryFilter selects records by status, target and time. Zero fields match
+// everything; Target matches a substring of the redacted target URL.
+type HistoryFilter struct {
+	Status MigrationStatus
+	Target string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f HistoryFilter) Match(r MigrationRecord) bool {
+	switch {
+	case f.Status != "" && r.Status != f.Status:
+		return false
+	case f.Target != "" && !strings.Contains(strings.ToLower(r.Target), strings.ToLower(f.Target)):
+		return false
+	case !f.Since.IsZero() && r.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !r.Timestamp.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+func FilterHistory(history []MigrationRecord, f HistoryFilter) []MigrationRecord {
+	filtered := []MigrationRecord{}
+	for _, r := range history {
+		if f.Match(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// LogTail returns the last n lines of a run log.
+func LogTail(path string, n int) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
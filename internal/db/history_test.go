@@ -0,0 +1,161 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"pgsync/internal/config"
+)
+
+func TestPruneHistory(t *testing.T) {
+	now := time.Now()
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	records := []MigrationRecord{
+		{ID: "a", Timestamp: days(40)},
+		{ID: "b", Timestamp: days(20)},
+		{ID: "c", Timestamp: days(10)},
+		{ID: "d", Timestamp: days(1)},
+	}
+	tests := []struct {
+		name      string
+		retention config.HistoryConfig
+		want      []string
+	}{
+		{"defaults keep everything", config.HistoryConfig{}, []string{"a", "b", "c", "d"}},
+		{"max runs", config.HistoryConfig{MaxRuns: 2}, []string{"c", "d"}},
+		{"unlimited runs", config.HistoryConfig{MaxRuns: -1}, []string{"a", "b", "c", "d"}},
+		{"max age", config.HistoryConfig{MaxAgeDays: 15}, []string{"c", "d"}},
+		{"both limits", config.HistoryConfig{MaxRuns: 1, MaxAgeDays: 30}, []string{"d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := writeHistory(dir, records); err != nil {
+				t.Fatal(err)
+			}
+			if err := pruneHistory(dir, tt.retention); err != nil {
+				t.Fatal(err)
+			}
+			history, err := readHistory(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordIDs(history); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneHistoryDeletesLogs(t *testing.T) {
+	dir := t.TempDir()
+	logs := logDir(dir)
+	if err := os.MkdirAll(logs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	elsewhere := filepath.Join(t.TempDir(), "run.log")
+	paths := []string{filepath.Join(logs, "old.log"), elsewhere, filepath.Join(logs, "new.log")}
+	for _, p := range paths {
+		if err := os.WriteFile(p, []byte("log\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	records := []MigrationRecord{
+		{ID: "old", Timestamp: now.Add(-3 * time.Hour), LogPath: paths[0]},
+		{ID: "elsewhere", Timestamp: now.Add(-2 * time.Hour), LogPath: paths[1]},
+		{ID: "new", Timestamp: now.Add(-time.Hour), LogPath: paths[2]},
+	}
+	if err := writeHistory(dir, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneHistory(dir, config.HistoryConfig{MaxRuns: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("log of a pruned run was kept")
+	}
+	// Only logs pgsync wrote itself are deleted.
+	if _, err := os.Stat(paths[1]); err != nil {
+		t.Errorf("log outside the logs directory was deleted: %v", err)
+	}
+	if _, err := os.Stat(paths[2]); err != nil {
+		t.Errorf("log of a kept run was deleted: %v", err)
+	}
+}
+
+func TestSaveAndLoadHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".pgsync")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash left a partial last line behind.
+	first, _ := json.Marshal(MigrationRecord{ID: "a", Timestamp: time.Now().Add(-3 * time.Hour)})
+	partial := append(first, []byte("\n{\"id\":\"cut")...)
+	if err := os.WriteFile(filepath.Join(dir, historyFile), partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	retention := config.HistoryConfig{MaxRuns: 2}
+	for i, id := range []string{"b", "c"} {
+		rec := MigrationRecord{ID: id, Timestamp: time.Now().Add(time.Duration(i-2) * time.Hour)}
+		if err := SaveHistory(rec, retention); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordIDs(history), []string{"c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadHistory = %q, want %q", got, want)
+	}
+}
+
+func TestMigrateLegacyHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".pgsync")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	legacy, _ := json.Marshal([]MigrationRecord{
+		{ID: "new", Timestamp: now.Add(-time.Hour)},
+		{ID: "old", Timestamp: now.Add(-2 * time.Hour)},
+	})
+	if err := os.WriteFile(filepath.Join(dir, legacyHistoryFile), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveHistory(MigrationRecord{ID: "latest", Timestamp: now}, config.HistoryConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordIDs(history), []string{"latest", "new", "old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadHistory = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, legacyHistoryFile+".migrated")); err != nil {
+		t.Errorf("legacy history was not set aside: %v", err)
+	}
+}
+
+func recordIDs(history []MigrationRecord) []string {
+	ids := []string{}
+	for _, rec := range history {
+		ids = append(ids, rec.ID)
+	}
+	return ids
+}
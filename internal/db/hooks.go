@@ -0,0 +1,103 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"pgsync/internal/config"
+)
+
+type HookPoint string
+
+const (
+	HookBeforeBackup  HookPoint = "before_backup"
+	HookBeforeDump    HookPoint = "before_dump"
+	HookBeforeRestore HookPoint = "before_restore"
+	HookAfterRestore  HookPoint = "after_restore"
+	HookOnFailure     HookPoint = "on_failure"
+)
+
+func hooksFor(h config.HooksConfig, point HookPoint) []config.Hook {
+	switch point {
+	case HookBeforeBackup:
+		return h.BeforeBackup
+	case HookBeforeDump:
+		return h.BeforeDump
+	case HookBeforeRestore:
+		return h.BeforeRestore
+	case HookAfterRestore:
+		return h.AfterRestore
+	case HookOnFailure:
+		return h.OnFailure
+	}
+	return nil
+}
+
+func (m *Migrator) runHooks(point HookPoint, pct float64, runErr error) error {
+	hooks := hooksFor(m.options.Hooks, point)
+	for i, hook := range hooks {
+		label := hook.Shell
+		if hook.SQL != "" {
+			label = fmt.Sprintf("psql %s -f %s", hook.On, hook.SQL)
+		}
+		m.sendProgress(pct, fmt.Sprintf("Running %s hook %d/%d...", point, i+1, len(hooks)), label)
+		m.writeLog("Running %s hook: %s", point, label)
+
+		out, err := m.runHook(point, hook, runErr)
+		if len(out) > 0 {
+			m.writeLog("Hook output:\n%s", strings.TrimRight(string(out), "\n"))
+		}
+		if err != nil {
+			m.writeLog("Hook failed: %v", err)
+			return fmt.Errorf("%s hook %q failed: %v", point, label, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) runHook(point HookPoint, hook config.Hook, runErr error) ([]byte, error) {
+	if hook.Shell != "" {
+		// Hooks get the URLs without passwords; libpq finds them in the
+		// password file, as pg_dump and pg_restore do.
+		for _, u := range []string{m.source, m.target} {
+			if err := passwordToPassfile(u); err != nil {
+				return nil, err
+			}
+		}
+		cmd := shellCommand(hook.Shell)
+		cmd.Env = append(os.Environ(), m.hookEnv(point, runErr)...)
+		return cmd.CombinedOutput()
+	}
+
+	url := m.target
+	if hook.On == "source" {
+		url = m.source
+	}
+	if url == "" {
+		return nil, fmt.Errorf("no %s database in this run", hook.On)
+	}
+	return exec.Command("psql", url, "-w", "-X", "-v", "ON_ERROR_STOP=1", "-f", hook.SQL).CombinedOutput()
+}
+
+func (m *Migrator) hookEnv(point HookPoint, runErr error) []string {
+	env := []string{
+		"PGSYNC_HOOK=" + string(point),
+		"PGSYNC_RUN_ID=" + m.runID,
+		"PGSYNC_MIGRATION_TYPE=" + string(m.migrationType),
+		"PGSYNC_SOURCE=" + RedactURL(m.givenSource),
+		"PGSYNC_TARGET=" + RedactURL(m.givenTarget),
+		"PGSYNC_SOURCE_URL=" + StripPassword(m.source),
+		"PGSYNC_TARGET_URL=" + StripPassword(m.target),
+		"PGSYNC_LOG_PATH=" + m.stats.LogPath,
+		"PGSYNC_BACKUP_PATH=" + m.stats.BackupPath,
+		"PGSYNC_TABLES=" + strings.Join(m.options.SelectedTables, ","),
+	}
+	if runErr != nil {
+		env = append(env, "PGSYNC_STATUS=failed", "PGSYNC_ERROR="+runErr.Error())
+	} else {
+		env = append(env, "PGSYNC_STATUS=running")
+	}
+	return env
+}
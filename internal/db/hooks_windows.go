@@ -0,0 +1,10 @@
+//go:build windows
+
+package db
+
+import "os/exec"
+
+// shellCommand runs a shell hook with cmd.exe.
+func shellCommand(script string) *exec.Cmd {
+	return exec.Command("cmd", "/C", script)
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LoadStrategy string
+
+const (
+	LoadTruncate LoadStrategy = "truncate"
+	LoadAppend   LoadStrategy = "append"
+	LoadUpsert   LoadStrategy = "upsert"
+)
+
+var LoadStrategies = []LoadStrategy{LoadTruncate, LoadAppend, LoadUpsert}
+
+type TableLoadResult struct {
+	Table    string       `json:"table"`
+	Strategy LoadStrategy `json:"strategy"`
+	Rows     int64        `json:"rows"`
+	Err      string       `json:"error,omitempty"`
+}
+
+var commandTagPattern = regexp.MustCompile(`(?m)^(COPY|INSERT 0) (\d+)$`)
+
+func (o MigrationOptions) StrategyFor(table string) LoadStrategy {
+	if s, ok := o.TableStrategies[table]; ok && s != "" {
+		return s
+	}
+	if o.LoadStrategy != "" {
+		return o.LoadStrategy
+	}
+	return LoadTruncate
+}
+
+func (m *Migrator) loadData(pct float64, step string) error {
+	tables := m.options.SelectedTables
+	if len(tables) == 0 {
+		var err error
+		tables, err = GetTables(m.source)
+		if err != nil {
+			return err
+		}
+	}
+	tables = m.options.FilterTables(tables)
+
+	start := time.Now()
+	defer m.recordPhase("Data load", start)
+
+	if err := m.truncateTables(tables, pct, step); err != nil {
+		return err
+	}
+
+	var failed []string
+	for i, table := range tables {
+		strategy := m.options.StrategyFor(table)
+		progress := pct + (0.95-pct)*float64(i)/float64(len(tables))
+		m.sendProgress(progress, fmt.Sprintf("%s: Loading %s (%s) [%d/%d]...", step, table, strategy, i+1, len(tables)), "COPY "+table+" FROM STDIN")
+
+		rows, err := m.loadTable(table, strategy)
+		result := TableLoadResult{Table: table, Strategy: strategy, Rows: rows}
+		if err != nil {
+			result.Err = err.Error()
+			failed = append(failed, table)
+			m.writeLog("Loading %s (%s) failed: %v", table, strategy, err)
+		} else {
+			m.writeLog("Loaded %s (%s): %d rows", table, strategy, rows)
+		}
+		m.stats.TableResults = append(m.stats.TableResults, result)
+	}
+
+	m.stats.TablesMigrated = len(tables) - len(failed)
+	if len(failed) > 0 {
+		return fmt.Errorf("data load failed for %d table(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// truncateTables empties the truncate-strategy tables in one statement before
+// anything is loaded. Foreign keys between them then don't block the
+// TRUNCATE, and CASCADE can't wipe a table that was already loaded. Tables
+// missing on the target are left for loadTable to report.
+func (m *Migrator) truncateTables(tables []string, pct float64, step string) error {
+	var truncate []string
+	for _, t := range tables {
+		if m.options.StrategyFor(t) == LoadTruncate {
+			truncate = append(truncate, t)
+		}
+	}
+	if len(truncate) == 0 {
+		return nil
+	}
+	existing, err := GetTables(m.target)
+	if err != nil {
+		return err
+	}
+
+	var quoted []string
+	for _, t := range truncate {
+		if contains(existing, t) {
+			quoted = append(quoted, quoteQualified(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	stmt := "TRUNCATE " + strings.Join(quoted, ", ")
+	if m.options.TruncateCascade {
+		stmt += " CASCADE"
+	}
+
+	m.sendProgress(pct, fmt.Sprintf("%s: Truncating %d table(s)...", step, len(quoted)), "TRUNCATE ...")
+	m.writeLog("Truncating %d table(s)", len(quoted))
+	cmd := exec.Command("psql", m.target, "-w", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	cmd.Stdin = strings.NewReader(stmt + ";\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("truncate failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// upsertTable returns a table the options load with the upsert strategy.
+// Imports can't upsert: the rows come from an archive, not through COPY.
+func (m *Migrator) upsertTable(tables []string) (string, bool) {
+	if m.options.LoadStrategy == LoadUpsert {
+		return "all tables", true
+	}
+	for _, t := range tables {
+		if m.options.StrategyFor(t) == LoadUpsert {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (m *Migrator) loadTable(table string, strategy LoadStrategy) (int64, error) {
+	qualified := quoteQualified(table)
+
+	columns, err := tableColumns(m.target, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("table not found on target")
+	}
+	colList := quoteIdents(columns)
+
+	var header, footer strings.Builder
+	header.WriteString("\\set ON_ERROR_STOP on\nBEGIN;\n")
+	if m.options.DisableTriggers {
+		header.WriteString("SET LOCAL session_replication_role = replica;\n")
+	}
+
+	switch strategy {
+	case LoadTruncate, LoadAppend:
+		// Truncate-strategy tables were emptied by truncateTables.
+		fmt.Fprintf(&header, "COPY %s (%s) FROM STDIN;\n", qualified, colList)
+	case LoadUpsert:
+		pk, err := primaryKey(m.target, table)
+		if err != nil {
+			return 0, err
+		}
+		if len(pk) == 0 {
+			return 0, fmt.Errorf("no primary key on target, cannot upsert")
+		}
+
+		var updates []string
+		for _, c := range columns {
+			if !contains(pk, c) {
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdent(c), quoteIdent(c)))
+			}
+		}
+		action := "DO NOTHING"
+		if len(updates) > 0 {
+			action = "DO UPDATE SET " + strings.Join(updates, ", ")
+		}
+
+		fmt.Fprintf(&header, "CREATE TEMP TABLE pgsync_stage (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP;\n", qualified)
+		fmt.Fprintf(&header, "COPY pgsync_stage (%s) FROM STDIN;\n", colList)
+		fmt.Fprintf(&footer, "INSERT INTO %s (%s) SELECT %s FROM pgsync_stage ON CONFLICT (%s) %s;\n",
+			qualified, colList, colList, quoteIdents(pk), action)
+	default:
+		return 0, fmt.Errorf("unknown load strategy %q", strategy)
+	}
+	footer.WriteString("COMMIT;\n")
+
+	copyOut := exec.Command("psql", m.source, "-w", "-X", "-q", "-c",
+		fmt.Sprintf("COPY (SELECT %s FROM %s) TO STDOUT", colList, qualified))
+	var copyErr bytes.Buffer
+	copyOut.Stderr = &copyErr
+	data, err := copyOut.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := copyOut.Start(); err != nil {
+		return 0, err
+	}
+
+	load := exec.Command("psql", m.target, "-w", "-X")
+	load.Stdin = io.MultiReader(strings.NewReader(header.String()), data, strings.NewReader("\\.\n"+footer.String()))
+	m.writeLog("Loading %s with strategy %s", table, strategy)
+	out, loadErr := load.CombinedOutput()
+	if loadErr != nil {
+		copyOut.Process.Kill()
+	}
+	copyWaitErr := copyOut.Wait()
+
+	if loadErr != nil {
+		return 0, fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	if copyWaitErr != nil {
+		return 0, fmt.Errorf("reading source failed: %s", strings.TrimSpace(copyErr.String()))
+	}
+
+	var rows int64
+	for _, match := range commandTagPattern.FindAllStringSubmatch(string(out), -1) {
+		n, _ := strconv.ParseInt(match[2], 10, 64)
+		rows = n
+	}
+	return rows, nil
+}
+
+func tableColumns(url, table string) ([]string, error) {
+	query := fmt.Sprintf("SELECT attname FROM pg_attribute WHERE attrelid = %s::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = '' ORDER BY attnum;", quoteLiteral(quoteQualified(table)))
+	return queryColumn(url, query)
+}
+
+func primaryKey(url, table string) ([]string, error) {
+	query := fmt.Sprintf("SELECT a.attname FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey) WHERE i.indrelid = %s::regclass AND i.indisprimary ORDER BY array_position(i.indkey::int2[], a.attnum);", quoteLiteral(quoteQualified(table)))
+	return queryColumn(url, query)
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type DatabaseLocale struct {
+	Encoding    string `json:"encoding"`
+	Collate     string `json:"collate"`
+	Ctype       string `json:"ctype"`
+	Provider    string `json:"provider,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	CollVersion string `json:"coll_version,omitempty"`
+}
+
+func (l DatabaseLocale) String() string {
+	s := l.Collate
+	if l.Ctype != l.Collate {
+		s += "/" + l.Ctype
+	}
+	if l.Provider != "" && l.Provider != "libc" {
+		s = l.Provider + " " + l.Locale
+	}
+	if l.CollVersion != "" {
+		s += " v" + l.CollVersion
+	}
+	return s
+}
+
+func (l DatabaseLocale) sameCollation(o DatabaseLocale) bool {
+	return l.Collate == o.Collate && l.Ctype == o.Ctype && l.provider() == o.provider() && l.Locale == o.Locale
+}
+
+func (l DatabaseLocale) provider() string {
+	if l.Provider == "" {
+		return "libc"
+	}
+	return l.Provider
+}
+
+// Read through to_jsonb so the query works on servers that predate
+// datlocprovider (15), daticulocale (15-16) and datlocale (17).
+const databaseLocaleQuery = `SELECT pg_encoding_to_char(d.encoding), d.datcollate, d.datctype,
+	CASE to_jsonb(d)->>'datlocprovider' WHEN 'i' THEN 'icu' WHEN 'b' THEN 'builtin' ELSE 'libc' END,
+	COALESCE(to_jsonb(d)->>'datlocale', to_jsonb(d)->>'daticulocale', ''),
+	COALESCE(to_jsonb(d)->>'datcollversion', '')
+FROM pg_database d WHERE d.datname = current_database();`
+
+// Non-default collations referenced by user columns, with the number of
+// indexes that sort by them.
+const columnCollationsQuery = `SELECT quote_ident(cn.nspname) || '.' || quote_ident(co.collname),
+	cn.nspname = 'pg_catalog',
+	CASE co.collprovider WHEN 'i' THEN 'icu' WHEN 'b' THEN 'builtin' ELSE 'libc' END,
+	COALESCE(to_jsonb(co)->>'collversion', ''),
+	count(DISTINCT a.attrelid::text || '.' || a.attnum::text),
+	(SELECT count(*) FROM pg_index i WHERE co.oid = ANY(i.indcollation::oid[]))
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid AND c.relkind IN ('r', 'p', 'm')
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_collation co ON co.oid = a.attcollation
+JOIN pg_namespace cn ON cn.oid = co.collnamespace
+WHERE a.attnum > 0 AND NOT a.attisdropped AND co.collname NOT IN ('default', 'C', 'POSIX')
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+GROUP BY 1, 2, 3, 4, co.oid ORDER BY 1;`
+
+// Indexes whose order depends on the database default collation.
+const defaultCollationIndexesQuery = `SELECT count(DISTINCT i.indexrelid)
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE 100 = ANY(i.indcollation::oid[]) AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%';`
+
+func getDatabaseLocale(url string) (*DatabaseLocale, error) {
+	rows, err := queryRows(url, databaseLocaleQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) < 6 {
+		return nil, fmt.Errorf("database locale not found")
+	}
+	r := rows[0]
+	return &DatabaseLocale{Encoding: r[0], Collate: r[1], Ctype: r[2], Provider: r[3], Locale: r[4], CollVersion: r[5]}, nil
+}
+
+func encodingCheck(src, tgt *DatabaseLocale) CheckResult {
+	const name = "Encoding"
+	if src.Encoding == tgt.Encoding {
+		return CheckResult{Name: name, Status: StatusGreen, Message: src.Encoding}
+	}
+	return CheckResult{Name: name, Status: StatusRed, Message: fmt.Sprintf("%s -> %s: text is converted on restore and characters the target can't represent fail to load; create the target database with ENCODING '%s'", src.Encoding, tgt.Encoding, src.Encoding)}
+}
+
+func collationCheck(src, tgt *DatabaseLocale, textIndexes int64, indexErr error) CheckResult {
+	const name = "Collation"
+	if src.sameCollation(*tgt) {
+		if src.CollVersion != "" && tgt.CollVersion != "" && src.CollVersion != tgt.CollVersion {
+			return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("%s: library version differs (%s -> %s); text sort order may change slightly", tgt.Collate, src.CollVersion, tgt.CollVersion)}
+		}
+		return CheckResult{Name: name, Status: StatusGreen, Message: tgt.String()}
+	}
+
+	msg := fmt.Sprintf("%s -> %s", src.String(), tgt.String())
+	switch {
+	case indexErr != nil:
+		return CheckResult{Name: name, Status: StatusYellow, Message: msg + "; sort order of text may differ"}
+	case textIndexes == 0:
+		return CheckResult{Name: name, Status: StatusGreen, Message: msg + "; no indexes use the default collation"}
+	}
+	return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("%s; %d text indexes and ORDER BY results will follow the target collation", msg, textIndexes)}
+}
+
+func columnCollationsCheck(source, target string) CheckResult {
+	const name = "Column Collations"
+	rows, err := queryRows(source, columnCollationsQuery)
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not list column collations: " + err.Error()}
+	}
+	if len(rows) == 0 {
+		return CheckResult{Name: name, Status: StatusGreen, Message: "Columns use the database default"}
+	}
+
+	tgtRows, err := queryRows(target, "SELECT quote_ident(n.nspname) || '.' || quote_ident(c.collname), COALESCE(to_jsonb(c)->>'collversion', '') FROM pg_collation c JOIN pg_namespace n ON n.oid = c.collnamespace WHERE n.nspname = 'pg_catalog';")
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not list target collations: " + err.Error()}
+	}
+	tgtVersions := make(map[string]string)
+	for _, r := range tgtRows {
+		if len(r) == 2 {
+			tgtVersions[r[0]] = r[1]
+		}
+	}
+
+	var missing, changed, used []string
+	for _, r := range rows {
+		if len(r) < 6 {
+			continue
+		}
+		coll, builtin, version := r[0], r[1] == "t", r[3]
+		indexes, _ := strconv.Atoi(r[5])
+		used = append(used, coll)
+
+		// User-defined collations are part of the dump; only predefined ones
+		// have to exist on the target already.
+		if !builtin {
+			continue
+		}
+		tgtVersion, ok := tgtVersions[coll]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", coll, r[2]))
+			continue
+		}
+		if indexes > 0 && version != "" && tgtVersion != "" && version != tgtVersion {
+			changed = append(changed, fmt.Sprintf("%s %s -> %s (%d indexes)", coll, version, tgtVersion, indexes))
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return CheckResult{Name: name, Status: StatusRed, Message: "Missing on target: " + strings.Join(missing, ", ")}
+	case len(changed) > 0:
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Collation versions differ: " + strings.Join(changed, ", ")}
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: "Available on target: " + strings.Join(used, ", ")}
+}
+
+func localeChecks(source, target string) []CheckResult {
+	src, err := getDatabaseLocale(source)
+	if err != nil {
+		return []CheckResult{{Name: "Encoding", Status: StatusYellow, Message: "Could not read source locale: " + err.Error()}}
+	}
+	tgt, err := getDatabaseLocale(target)
+	if err != nil {
+		return []CheckResult{{Name: "Encoding", Status: StatusYellow, Message: "Could not read target locale: " + err.Error()}}
+	}
+
+	textIndexes, indexErr := queryInt(source, defaultCollationIndexesQuery)
+	return []CheckResult{
+		encodingCheck(src, tgt),
+		collationCheck(src, tgt, textIndexes, indexErr),
+		columnCollationsCheck(source, target),
+	}
+}
+
+// manifestLocaleChecks compares the locale recorded in a snapshot with the
+// target. Column collations are not part of the manifest.
+func manifestLocaleChecks(src *DatabaseLocale, target string) []CheckResult {
+	if src == nil {
+		return nil
+	}
+	tgt, err := getDatabaseLocale(target)
+	if err != nil {
+		return []CheckResult{{Name: "Encoding", Status: StatusYellow, Message: "Could not read target locale: " + err.Error()}}
+	}
+	return []CheckResult{
+		encodingCheck(src, tgt),
+		collationCheck(src, tgt, 0, fmt.Errorf("index usage not recorded in snapshot")),
+	}
+}
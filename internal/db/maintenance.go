@@ -0,0 +1,178 @@
+package db
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type AnalyzeMode string
+
+const (
+	AnalyzeOff    AnalyzeMode = "off"
+	AnalyzeFull   AnalyzeMode = "analyze"
+	AnalyzeStages AnalyzeMode = "stages"
+)
+
+var AnalyzeModes = []AnalyzeMode{AnalyzeFull, AnalyzeStages, AnalyzeOff}
+
+type PhaseTiming struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (m *Migrator) recordPhase(name string, start time.Time) {
+	m.stats.Phases = append(m.stats.Phases, PhaseTiming{Name: name, Duration: time.Since(start).Round(time.Millisecond)})
+}
+
+func (m *Migrator) runMaintenance(pct float64, step string) {
+	if m.migrationType == SchemaOnly {
+		return
+	}
+
+	switch m.options.Analyze {
+	case AnalyzeFull:
+		m.analyzeTables(pct, step)
+	case AnalyzeStages:
+		m.vacuumdbStages(pct, step)
+	}
+
+	if m.options.RefreshMatViews {
+		m.refreshMatViews(pct, step)
+	}
+}
+
+func (m *Migrator) analyzeTables(pct float64, step string) {
+	query := "ANALYZE;"
+	if len(m.options.SelectedTables) > 0 {
+		tables := make([]string, len(m.options.SelectedTables))
+		for i, t := range m.options.SelectedTables {
+			tables[i] = quoteQualified(t)
+		}
+		query = "ANALYZE " + strings.Join(tables, ", ") + ";"
+	}
+
+	m.sendProgress(pct, step+": Updating planner statistics...", "psql ... "+truncateCommand(query))
+	m.writeLog("Running maintenance: %s", query)
+
+	start := time.Now()
+	out, err := exec.Command("psql", m.target, "-w", "-X", "-c", query).CombinedOutput()
+	m.recordPhase("ANALYZE", start)
+	if err != nil {
+		m.writeLog("ANALYZE failed: %s", string(out))
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("ANALYZE failed: %s", strings.TrimSpace(string(out))))
+	}
+}
+
+func (m *Migrator) vacuumdbStages(pct float64, step string) {
+	args := []string{"--analyze-in-stages", "-j", m.jobs(), "-d", m.target}
+	// vacuumdb reads -t as SQL, so names are quoted like in ANALYZE.
+	for _, t := range m.options.SelectedTables {
+		args = append(args, "-t", quoteQualified(t))
+	}
+
+	m.sendProgress(pct, step+": Analyzing in stages...", fmt.Sprintf("vacuumdb --analyze-in-stages -j %s -d %s", m.jobs(), RedactURL(m.target)))
+	m.writeLog("Running maintenance: vacuumdb %v", redactArgs(args))
+
+	start := time.Now()
+	out, err := exec.Command("vacuumdb", args...).CombinedOutput()
+	m.recordPhase("vacuumdb --analyze-in-stages", start)
+	if len(out) > 0 {
+		m.writeLog("vacuumdb output:\n%s", strings.TrimRight(string(out), "\n"))
+	}
+	if err != nil {
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("vacuumdb failed: %s", strings.TrimSpace(string(out))))
+	}
+}
+
+func (m *Migrator) refreshMatViews(pct float64, step string) {
+	views, err := matViewsInOrder(m.target)
+	if err != nil {
+		m.writeLog("Listing materialized views failed: %v", err)
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Could not list materialized views: %v", err))
+		return
+	}
+
+	for i, view := range views {
+		query := fmt.Sprintf("REFRESH MATERIALIZED VIEW %s;", view)
+		m.sendProgress(pct, fmt.Sprintf("%s: Refreshing materialized views [%d/%d]...", step, i+1, len(views)), query)
+		m.writeLog("Running maintenance: %s", query)
+
+		start := time.Now()
+		out, err := exec.Command("psql", m.target, "-w", "-X", "-c", query).CombinedOutput()
+		m.recordPhase("REFRESH "+view, start)
+		if err != nil {
+			m.writeLog("Refresh of %s failed: %s", view, string(out))
+			m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Refresh of %s failed: %s", view, strings.TrimSpace(string(out))))
+		}
+	}
+}
+
+// matViewDependencyQuery lists the materialized views each materialized view
+// reads, following plain views in between transitively.
+const matViewDependencyQuery = `WITH RECURSIVE refs(matview, relid) AS (
+	SELECT r.ev_class, dep.refobjid
+	FROM pg_rewrite r
+	JOIN pg_class v ON v.oid = r.ev_class AND v.relkind = 'm'
+	JOIN pg_depend dep ON dep.objid = r.oid AND dep.classid = 'pg_rewrite'::regclass AND dep.refclassid = 'pg_class'::regclass
+	WHERE dep.refobjid <> r.ev_class
+UNION
+	SELECT refs.matview, dep.refobjid
+	FROM refs
+	JOIN pg_class c ON c.oid = refs.relid AND c.relkind = 'v'
+	JOIN pg_rewrite r ON r.ev_class = c.oid
+	JOIN pg_depend dep ON dep.objid = r.oid AND dep.classid = 'pg_rewrite'::regclass AND dep.refclassid = 'pg_class'::regclass
+	WHERE dep.refobjid <> c.oid
+)
+SELECT DISTINCT quote_ident(vn.nspname) || '.' || quote_ident(v.relname),
+	quote_ident(dn.nspname) || '.' || quote_ident(d.relname)
+FROM refs
+JOIN pg_class v ON v.oid = refs.matview
+JOIN pg_namespace vn ON vn.oid = v.relnamespace
+JOIN pg_class d ON d.oid = refs.relid AND d.relkind = 'm' AND d.oid <> v.oid
+JOIN pg_namespace dn ON dn.oid = d.relnamespace;`
+
+func matViewsInOrder(url string) ([]string, error) {
+	views, err := queryColumn(url, "SELECT quote_ident(schemaname) || '.' || quote_ident(matviewname) FROM pg_matviews WHERE schemaname NOT IN ('information_schema', 'pg_catalog') ORDER BY 1;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryRows(url, matViewDependencyQuery)
+	if err != nil {
+		return nil, err
+	}
+	deps := make(map[string][]string)
+	for _, row := range rows {
+		if len(row) == 2 {
+			deps[row[0]] = append(deps[row[0]], row[1])
+		}
+	}
+
+	var ordered []string
+	state := make(map[string]int)
+	var visit func(v string)
+	visit = func(v string) {
+		if state[v] != 0 {
+			return
+		}
+		state[v] = 1
+		for _, d := range deps[v] {
+			visit(d)
+		}
+		state[v] = 2
+		ordered = append(ordered, v)
+	}
+	for _, v := range views {
+		visit(v)
+	}
+	return ordered, nil
+}
+
+func truncateCommand(s string) string {
+	if len(s) > 80 {
+		return s[:77] + "..."
+	}
+	return s
+}
@@ -3,9 +3,11 @@ package db
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
+
+	"pgsync/internal/config"
+	"pgsync/internal/storage"
 )
 
 type MigrationType string
@@ -27,6 +29,29 @@ type MigrationOptions struct {
 	SelectedTables []string
 	ParallelJobs   int
 	AutoBackup     bool
+	Storage        storage.Storage
+
+	LoadStrategy    LoadStrategy
+	TableStrategies map[string]LoadStrategy
+	TruncateCascade bool
+	DisableTriggers bool
+	SyncSequences   bool
+	Analyze         AnalyzeMode
+	RefreshMatViews bool
+
+	OnActivity      ActivityPolicy
+	ActivityTimeout time.Duration
+
+	Provider       string
+	ExcludeSchemas []string
+	SkipExtensions []string
+
+	Hooks    config.HooksConfig
+	History  config.HistoryConfig
+	Profiles []config.Profile
+
+	SourceSSH *config.SSHConfig
+	TargetSSH *config.SSHConfig
 }
 
 type MigrationStats struct {
@@ -38,25 +63,47 @@ type MigrationStats struct {
 	DidRollback     bool
 	RollbackSuccess bool
 	LogPath         string
+	TableResults    []TableLoadResult
+	SequenceDrift   []SequenceSync
+	Phases          []PhaseTiming
+	SourceBytes     int64
+	BytesDumped     int64
 }
 
 type Migrator struct {
 	source        string
 	target        string
+	givenSource   string
+	givenTarget   string
 	migrationType MigrationType
 	options       MigrationOptions
 	progressChan  chan<- ProgressUpdate
 	stats         MigrationStats
-	backupPath    string
+	store         storage.Storage
+	backupKey     string
+	logFile       *os.File
+	runID         string
+	tools         ClientTools
+	backupTools   ClientTools
+	targetMajor   int
+	lock          *targetLock
+	tunnels       tunnels
 }
 
 func NewMigrator(source, target string, migrationType MigrationType, options MigrationOptions, progressChan chan<- ProgressUpdate) *Migrator {
+	store := options.Storage
+	if store == nil {
+		store = storage.NewLocal("")
+	}
 	return &Migrator{
 		source:        source,
 		target:        target,
+		givenSource:   source,
+		givenTarget:   target,
 		migrationType: migrationType,
-		options:       options,
+		options:       options.WithProvider(target, source),
 		progressChan:  progressChan,
+		store:         store,
 	}
 }
 
@@ -64,96 +111,296 @@ func (m *Migrator) Migrate() (*MigrationStats, error) {
 	startTime := time.Now()
 	var finalErr error
 
+	m.begin()
+	defer m.finish(startTime, m.source, m.target, &finalErr)
+
+	m.writeLog("Starting migration %s -> %s (Type: %s)", RedactURL(m.source), RedactURL(m.target), m.migrationType)
+	if err := CheckProtectedTarget(m.target, m.options); err != nil {
+		m.writeLog("%v", err)
+		finalErr = err
+		return &m.stats, finalErr
+	}
+	if err := m.openTunnels(); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.sendProgress(0.0, "Preparing migration tasks...", "")
+
+	m.sendProgress(0.1, "Step 1/5: Verifying source connection...", "pg_isready -d "+RedactURL(m.source))
+	if err := CheckConnection(m.source); err != nil {
+		finalErr = fmt.Errorf("source database: %w", err)
+		return &m.stats, finalErr
+	}
+
+	m.sendProgress(0.2, "Step 1/5: Verifying target connection...", "pg_isready -d "+RedactURL(m.target))
+	if err := CheckConnection(m.target); err != nil {
+		finalErr = fmt.Errorf("target database: %w", err)
+		return &m.stats, finalErr
+	}
+
+	m.measureSource()
+
+	if m.migrationType != DataOnly || m.options.AutoBackup {
+		sourceMajor := 0
+		if m.migrationType != DataOnly {
+			sourceMajor = serverMajor(m.source)
+		}
+		if err := m.selectTools(sourceMajor); err != nil {
+			finalErr = err
+			return &m.stats, finalErr
+		}
+	}
+
+	if err := m.acquireTargetLock(); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.runHooks(HookBeforeBackup, 0.25, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if m.options.AutoBackup {
+		if err := m.backupTarget(0.3, "Step 2/5"); err != nil {
+			finalErr = err
+			return &m.stats, finalErr
+		}
+	}
+
+	if err := m.runHooks(HookBeforeDump, 0.4, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if m.migrationType == DataOnly {
+		if err := m.runHooks(HookBeforeRestore, 0.4, nil); err != nil {
+			finalErr = err
+			return &m.stats, finalErr
+		}
+
+		if err := m.ensureTargetIdle(0.4, "Step 3/5"); err != nil {
+			finalErr = err
+			return &m.stats, finalErr
+		}
+
+		if err := m.loadData(0.4, "Step 3/5"); err != nil {
+			m.writeLog("Data load failed: %v", err)
+			finalErr = m.rollback(err, 0.95)
+			return &m.stats, finalErr
+		}
+
+		m.afterRestore(0.95, "Step 5/5")
+
+		m.sendProgress(1.0, "Step 5/5: Migration completed!", "")
+		return &m.stats, nil
+	}
+
+	m.sendProgress(0.4, "Step 3/5: Dumping source database...", "")
+	dumpStore := m.store
+	if storage.IsLocal(dumpStore) {
+		dumpStore = storage.NewLocal(os.TempDir())
+	}
+	dumpKey := fmt.Sprintf("pgsync_dump_%d.dump", time.Now().UnixNano())
+	defer dumpStore.Delete(dumpKey)
+
+	dumpCmdStr := fmt.Sprintf("pg_dump %s ... (tables: %d)", RedactURL(m.source), len(m.options.SelectedTables))
+	m.sendProgress(0.5, "Step 3/5: Dumping content...", dumpCmdStr)
+
+	if _, err := m.dumpSource(dumpStore, dumpKey); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.runHooks(HookBeforeRestore, 0.75, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.ensureTargetIdle(0.8, "Step 4/5"); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.restoreTarget(dumpStore, dumpKey, 0.8, "Step 4/5"); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.afterRestore(0.95, "Step 5/5")
+
+	m.sendProgress(1.0, "Step 5/5: Migration completed!", "")
+
+	return &m.stats, nil
+}
+
+func (m *Migrator) begin() {
+	m.runID = fmt.Sprintf("%d", time.Now().UnixNano())
 	m.stats = MigrationStats{
 		MigrationType: m.migrationType,
 		Warnings:      []string{},
 	}
 
-	logFile, err := os.CreateTemp("", "pgsync_migration_*.log")
+	logFile, err := createRunLog(m.runID)
 	if err == nil {
+		m.logFile = logFile
 		m.stats.LogPath = logFile.Name()
-		defer logFile.Close()
 	}
 
-	writeLog := func(format string, args ...interface{}) {
-		if logFile != nil {
-			msg := fmt.Sprintf(format, args...)
-			timestamp := time.Now().Format("15:04:05")
-			logFile.WriteString(fmt.Sprintf("[%s] %s\n", timestamp, msg))
+	if len(m.options.SelectedTables) > 0 {
+		m.stats.TablesMigrated = len(m.options.SelectedTables)
+	}
+}
+
+func (m *Migrator) finish(startTime time.Time, source, target string, finalErr *error) {
+	if *finalErr != nil {
+		if err := m.runHooks(HookOnFailure, 0.99, *finalErr); err != nil {
+			m.stats.Warnings = append(m.stats.Warnings, err.Error())
 		}
 	}
 
-	writeLog("Starting migration %s -> %s (Type: %s)", redactURL(m.source), redactURL(m.target), m.migrationType)
+	m.releaseTargetLock()
 
-	if len(m.options.SelectedTables) > 0 {
-		m.stats.TablesMigrated = len(m.options.SelectedTables)
+	m.stats.Duration = time.Since(startTime).Round(time.Second).String()
+
+	status := StatusSuccess
+	errMsg := ""
+	if *finalErr != nil {
+		status = StatusFailed
+		errMsg = (*finalErr).Error()
 	}
 
-	defer func() {
-		m.stats.Duration = time.Since(startTime).Round(time.Second).String()
+	record := MigrationRecord{
+		ID:            m.runID,
+		Timestamp:     startTime,
+		Source:        RedactURL(source),
+		Target:        RedactURL(target),
+		MigrationType: m.migrationType,
+		Status:        status,
+		Duration:      m.stats.Duration,
+		Error:         errMsg,
+		SourceBytes:   m.stats.SourceBytes,
+		BytesDumped:   m.stats.BytesDumped,
+		TableCount:    m.stats.TablesMigrated,
+		Phases:        m.stats.Phases,
+
+		Options:         recordOptions(m.options),
+		SelectedTables:  m.options.SelectedTables,
+		Warnings:        m.stats.Warnings,
+		BackupPath:      m.stats.BackupPath,
+		BackupKey:       m.backupKey,
+		LogPath:         m.stats.LogPath,
+		TableResults:    m.stats.TableResults,
+		SequenceDrift:   m.stats.SequenceDrift,
+		DidRollback:     m.stats.DidRollback,
+		RollbackSuccess: m.stats.RollbackSuccess,
+		PgsyncVersion:   PgsyncVersion,
+		PgDumpVersion:   m.tools.Version,
+	}
+	if err := SaveHistory(record, m.options.History); err != nil {
+		m.writeLog("Failed to save history: %v", err)
+	}
 
-		status := StatusSuccess
-		errMsg := ""
-		if finalErr != nil {
-			status = StatusFailed
-			errMsg = finalErr.Error()
-		}
+	if m.logFile != nil {
+		m.logFile.Close()
+		m.logFile = nil
+	}
+	m.tunnels.Close()
+}
+
+// openTunnels opens the SSH tunnels of the run and points the migrator at
+// them. finish closes them after the last hook has run.
+func (m *Migrator) openTunnels() error {
+	t, err := openTunnels(m.options, m.source, m.target)
+	if err != nil {
+		m.writeLog("%v", err)
+		return err
+	}
+	m.tunnels = t
+	for _, tun := range t {
+		m.writeLog("SSH tunnel: %s", tun)
+	}
+	// givenSource and givenTarget keep the real hosts for manifests and
+	// hooks.
+	m.source, m.target = t.url(m.source), t.url(m.target)
+	return nil
+}
 
-		record := MigrationRecord{
-			Timestamp:     startTime,
-			Source:        redactURL(m.source),
-			Target:        redactURL(m.target),
-			MigrationType: m.migrationType,
-			Status:        status,
-			Duration:      m.stats.Duration,
-			Error:         errMsg,
+// measureSource records the size of the data and the number of tables being
+// migrated, which later runs use to predict their duration.
+func (m *Migrator) measureSource() {
+	if b, err := getTableDataBytes(m.source); err == nil {
+		m.stats.SourceBytes = b
+	}
+	if m.stats.TablesMigrated == 0 {
+		if n, err := getTableCount(m.source); err == nil {
+			m.stats.TablesMigrated = n
 		}
-		_ = SaveHistory(record)
-	}()
+	}
+}
 
-	m.sendProgress(0.0, "Preparing migration tasks...", "")
+func (m *Migrator) afterRestore(pct float64, step string) {
+	if m.options.SyncSequences && m.migrationType != SchemaOnly && m.source != "" {
+		m.syncSequences(pct, step)
+	}
 
-	m.sendProgress(0.1, "Step 1/5: Verifying source connection...", "pg_isready -d "+redactURL(m.source))
-	if err := CheckConnection(m.source); err != nil {
-		finalErr = fmt.Errorf("source database: %w", err)
-		return &m.stats, finalErr
+	m.runMaintenance(pct, step)
+
+	if err := m.runHooks(HookAfterRestore, pct, nil); err != nil {
+		m.stats.Warnings = append(m.stats.Warnings, err.Error())
 	}
+}
 
-	m.sendProgress(0.2, "Step 1/5: Verifying target connection...", "pg_isready -d "+redactURL(m.target))
-	if err := CheckConnection(m.target); err != nil {
-		finalErr = fmt.Errorf("target database: %w", err)
-		return &m.stats, finalErr
+func (m *Migrator) writeLog(format string, args ...interface{}) {
+	if m.logFile != nil {
+		msg := fmt.Sprintf(format, args...)
+		timestamp := time.Now().Format("15:04:05")
+		m.logFile.WriteString(fmt.Sprintf("[%s] %s\n", timestamp, msg))
 	}
+}
 
-	if m.options.AutoBackup {
-		backupFile := fmt.Sprintf("backup_target_%d.dump", time.Now().Unix())
-		m.sendProgress(0.3, "Step 2/5: Creating safety backup of target...", "pg_dump ... -w > "+backupFile)
-
-		backupCmd := exec.Command("pg_dump", "-d", m.target, "-w", "-Fc", "-f", backupFile)
-		writeLog("Running backup command: %v", backupCmd.Args)
-		if out, err := backupCmd.CombinedOutput(); err != nil {
-			writeLog("Backup failed: %s", string(out))
-			warning := fmt.Sprintf("Safety backup failed: %s", string(out))
-			m.stats.Warnings = append(m.stats.Warnings, warning)
-			m.sendProgress(0.3, "Warning: Safety backup failed, proceeding...", string(out))
-		} else {
-			m.backupPath = backupFile
-			m.stats.BackupPath = backupFile
-			m.sendProgress(0.35, "Step 2/5: Safety backup created: "+backupFile, "")
-		}
+func (m *Migrator) jobs() string {
+	if m.options.ParallelJobs > 0 {
+		return fmt.Sprintf("%d", m.options.ParallelJobs)
 	}
+	return "4"
+}
 
-	m.sendProgress(0.4, "Step 3/5: Dumping source database...", "")
-	tmpFile, err := os.CreateTemp("", "pgsync_dump_*.dump")
+// backupTarget dumps the target for rollback. A failed backup is only a
+// warning, except on a protected target, where the run stops.
+func (m *Migrator) backupTarget(pct float64, step string) error {
+	backupKey := fmt.Sprintf("backup_target_%d.dump", time.Now().Unix())
+	backupLoc := m.store.Location(backupKey)
+	m.sendProgress(pct, step+": Creating safety backup of target...", "pg_dump ... -w > "+backupLoc)
+
+	backupArgs := m.backupArgs()
+	m.writeLog("Running backup command: pg_dump %v > %s", redactArgs(backupArgs), backupLoc)
+	start := time.Now()
+	_, out, err := DumpToStorage(m.backupTools.Path("pg_dump"), m.store, backupKey, backupArgs)
+	m.recordPhase("Safety backup", start)
 	if err != nil {
-		finalErr = fmt.Errorf("failed to create temp file: %w", err)
-		return &m.stats, finalErr
+		m.writeLog("Backup failed: %v: %s", err, string(out))
+		if p := TargetProfile(m.target, m.options.Profiles); p.IsProtected() {
+			return fmt.Errorf("safety backup of protected target failed, nothing was changed: %v %s", err, strings.TrimSpace(string(out)))
+		}
+		warning := fmt.Sprintf("Safety backup failed: %v %s", err, string(out))
+		m.stats.Warnings = append(m.stats.Warnings, warning)
+		m.sendProgress(pct, "Warning: Safety backup failed, proceeding...", string(out))
+	} else {
+		m.backupKey = backupKey
+		m.stats.BackupPath = backupLoc
+		m.sendProgress(pct+0.05, step+": Safety backup created: "+backupLoc, "")
 	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
+	return nil
+}
+
+func (m *Migrator) backupArgs() []string {
+	return append([]string{"-d", m.target, "-w", "-Fc"}, m.excludeArgs()...)
+}
 
-	args := []string{m.source, "-w", "-Fc", "-f", tmpPath}
+func (m *Migrator) dumpArgs() []string {
+	args := []string{m.source, "-w", "-Fc"}
 	switch m.migrationType {
 	case SchemaOnly:
 		args = append(args, "--schema-only")
@@ -161,90 +408,102 @@ func (m *Migrator) Migrate() (*MigrationStats, error) {
 		args = append(args, "--data-only")
 	}
 
+	// pg_dump ignores -N once -t is given, so excluded tables are dropped here.
 	if len(m.options.SelectedTables) > 0 {
-		for _, t := range m.options.SelectedTables {
+		for _, t := range m.options.FilterTables(m.options.SelectedTables) {
 			args = append(args, "-t", t)
 		}
+	} else {
+		args = append(args, m.excludeArgs()...)
 	}
 
-	args = append(args, "--no-owner", "--no-privileges", "--verbose")
+	return append(args, "--no-owner", "--no-privileges", "--verbose")
+}
 
-	dumpCmdStr := fmt.Sprintf("pg_dump %s ... (tables: %d)", redactURL(m.source), len(m.options.SelectedTables))
-	m.sendProgress(0.5, "Step 3/5: Dumping content...", dumpCmdStr)
+func (m *Migrator) dumpSource(store storage.Storage, key string) (int64, error) {
+	args := m.dumpArgs()
+	m.writeLog("Running dump command: pg_dump %v > %s", redactArgs(args), store.Location(key))
+	start := time.Now()
+	size, output, err := DumpToStorage(m.tools.Path("pg_dump"), store, key, args)
+	m.recordPhase("Dump", start)
+	if err != nil {
+		m.writeLog("Dump failed: %v: %s", err, string(output))
+		return size, fmt.Errorf("dump failed: %s", string(output))
+	}
 
-	dumpCmd := exec.Command("pg_dump", args...)
-	writeLog("Running dump command: %v", dumpCmd.Args)
-	if output, err := dumpCmd.CombinedOutput(); err != nil {
-		writeLog("Dump failed: %s", string(output))
-		finalErr = fmt.Errorf("dump failed: %s", string(output))
-		return &m.stats, finalErr
+	m.writeLog("Dump size: %d bytes", size)
+	m.stats.BytesDumped = size
+	if size == 0 {
+		m.writeLog("Error: Dump file is empty")
+		return size, fmt.Errorf("dump file is empty (0 bytes) - check source database permissions or connectivity")
 	}
+	return size, nil
+}
 
-	if fi, err := os.Stat(tmpPath); err == nil {
-		writeLog("Dump file size: %d bytes", fi.Size())
-		if fi.Size() == 0 {
-			finalErr = fmt.Errorf("dump file is empty (0 bytes) - check source database permissions or connectivity")
-			writeLog("Error: Dump file is empty")
-			return &m.stats, finalErr
-		}
-	} else {
-		writeLog("Warning: Could not check dump file size: %v", err)
+// restoreFlags drop each object of the archive before recreating it.
+var restoreFlags = []string{"-w", "-c", "--if-exists", "--no-owner", "--no-privileges", "--verbose"}
+
+// dataRestoreFlags add the rows of a data-only archive to the existing
+// tables. There is nothing to drop; truncateTables empties them first.
+var dataRestoreFlags = []string{"-w", "--no-owner", "--no-privileges", "--verbose"}
+
+func (m *Migrator) restoreTarget(store storage.Storage, key string, pct float64, step string) error {
+	jobs := m.jobs()
+	restoreArgs := append([]string{}, restoreFlags...)
+	if m.migrationType == DataOnly {
+		restoreArgs = append([]string{}, dataRestoreFlags...)
+	}
+	protectArgs, cleanup := m.protectManagedObjects(m.tools, store, key)
+	defer cleanup()
+	restoreArgs = append(restoreArgs, protectArgs...)
+	restoreCmdStr := fmt.Sprintf("pg_restore -d %s -j %s ...", RedactURL(m.target), jobs)
+	if !storage.IsLocal(store) {
+		m.stats.Warnings = append(m.stats.Warnings, "Restored from object storage with a single job (parallel restore needs a local file)")
+		restoreCmdStr = fmt.Sprintf("pg_restore -d %s ... < %s", RedactURL(m.target), store.Location(key))
+	}
+	if skip := unsupportedSettings(m.tools.Major, m.targetMajor); len(skip) > 0 {
+		restoreCmdStr = fmt.Sprintf("pg_restore -f - ... | psql %s", RedactURL(m.target))
 	}
 
-	jobs := "4"
-	if m.options.ParallelJobs > 0 {
-		jobs = fmt.Sprintf("%d", m.options.ParallelJobs)
+	m.sendProgress(pct, fmt.Sprintf("%s: Parallel restore (j=%s)...", step, jobs), restoreCmdStr)
+
+	m.writeLog("Running restore command: %s %v < %s", m.tools.Path("pg_restore"), redactArgs(restoreArgs), store.Location(key))
+	start := time.Now()
+	output, err := m.runRestore(m.tools, store, key, restoreArgs)
+	m.recordPhase("Restore", start)
+	if err == nil {
+		return nil
 	}
 
-	restoreArgs := []string{"-d", m.target, "-w", "-j", jobs, "-c", "--if-exists", "--no-owner", "--no-privileges", "--verbose", tmpPath}
-	restoreCmdStr := fmt.Sprintf("pg_restore -d %s -j %s ...", redactURL(m.target), jobs)
+	m.writeLog("Restore failed: %s", string(output))
+	return m.rollback(fmt.Errorf("restore failed: %s", string(output)), pct+0.05)
+}
 
-	m.sendProgress(0.8, fmt.Sprintf("Step 4/5: Parallel restore (j=%s)...", jobs), restoreCmdStr)
+func (m *Migrator) rollback(cause error, pct float64) error {
+	if m.backupKey == "" {
+		return cause
+	}
 
-	restoreCmd := exec.Command("pg_restore", restoreArgs...)
-	writeLog("Running restore command: %v", restoreCmd.Args)
-	output, err := restoreCmd.CombinedOutput()
+	m.stats.DidRollback = true
+	m.sendProgress(pct, "Restore failed! Attempting rollback from backup...", "")
 
-	if err != nil {
-		outputStr := string(output)
-		if strings.Contains(outputStr, `unrecognized configuration parameter "transaction_timeout"`) {
-			writeLog("Restore returned error matches version mismatch pattern, treating as warning: %v", err)
-			writeLog("Output: %s", outputStr)
-			m.stats.Warnings = append(m.stats.Warnings, "Ignored benign 'transaction_timeout' errors (PG 17 -> Older DB)")
-		} else {
-			writeLog("Restore failed: %s", outputStr)
-			restoreErr := fmt.Errorf("restore failed: %s", outputStr)
-
-			if m.backupPath != "" {
-				m.stats.DidRollback = true
-				m.sendProgress(0.85, "Restore failed! Attempting rollback from backup...", "")
-
-				rollbackArgs := []string{"-d", m.target, "-w", "-c", "--if-exists", "-j", jobs, m.backupPath}
-				rollbackCmd := exec.Command("pg_restore", rollbackArgs...)
-				writeLog("Running rollback command: %v", rollbackCmd.Args)
-
-				if rbOut, rbErr := rollbackCmd.CombinedOutput(); rbErr != nil {
-					writeLog("Rollback failed: %s", string(rbOut))
-					m.stats.RollbackSuccess = false
-					m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Rollback also failed: %s", string(rbOut)))
-					finalErr = fmt.Errorf("%v (rollback also failed)", restoreErr)
-				} else {
-					writeLog("Rollback successful")
-					m.stats.RollbackSuccess = true
-					m.sendProgress(0.9, "Rollback successful! Target database restored to previous state.", "")
-					finalErr = fmt.Errorf("%v (rolled back successfully)", restoreErr)
-				}
-			} else {
-				finalErr = restoreErr
-			}
+	rollbackArgs := []string{"-w", "-c", "--if-exists"}
+	protectArgs, cleanup := m.protectManagedObjects(m.backupTools, m.store, m.backupKey)
+	defer cleanup()
+	rollbackArgs = append(rollbackArgs, protectArgs...)
+	m.writeLog("Running rollback command: %s %v < %s", m.backupTools.Path("pg_restore"), redactArgs(rollbackArgs), m.stats.BackupPath)
 
-			return &m.stats, finalErr
-		}
+	if rbOut, rbErr := m.runRestore(m.backupTools, m.store, m.backupKey, rollbackArgs); rbErr != nil {
+		m.writeLog("Rollback failed: %s", string(rbOut))
+		m.stats.RollbackSuccess = false
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Rollback also failed: %s", string(rbOut)))
+		return fmt.Errorf("%v (rollback also failed)", cause)
 	}
 
-	m.sendProgress(1.0, "Step 5/5: Migration completed!", "")
-
-	return &m.stats, nil
+	m.writeLog("Rollback successful")
+	m.stats.RollbackSuccess = true
+	m.sendProgress(pct+0.05, "Rollback successful! Target database restored to previous state.", "")
+	return fmt.Errorf("%v (rolled back successfully)", cause)
 }
 
 func (m *Migrator) sendProgress(percentage float64, message string, command string) {
@@ -256,7 +515,3 @@ func (m *Migrator) sendProgress(percentage float64, message string, command stri
 		}
 	}
 }
-
-func redactURL(url string) string {
-	return url
-}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"pgsync/internal/keyring"
+)
+
+// Passwords remembered in the keyring reach libpq through a private password
+// file instead of the URL: pg_dump, pg_restore and psql take the URL as an
+// argument, where ps shows it, and runs write their arguments to the log.
+// PGPASSFILE points every client tool pgsync starts at the file. The user's
+// own password file is copied after the keyring entries, so it still applies.
+var passfile struct {
+	sync.Mutex
+	path    string
+	entries []string
+}
+
+// The PGPASSFILE pgsync was started with; PgpassPath keeps reporting it.
+var userPassfile, userPassfileSet = os.LookupEnv("PGPASSFILE")
+
+// UseStoredPassword makes the password remembered in the keyring for connURL
+// available to the client tools. URLs that carry a password, and connections
+// with nothing remembered, are left alone.
+func UseStoredPassword(connURL string, kr keyring.Keyring) error {
+	if kr == nil || hasPassword(connURL) {
+		return nil
+	}
+	secret, err := kr.Get(CredentialKey(connURL))
+	if err != nil {
+		return nil
+	}
+	host, _, dbname, user, ok := connParams(connURL)
+	if !ok {
+		return nil
+	}
+	// Any port: through an SSH tunnel libpq connects to a local one.
+	return addPassfileEntry(pgpassLine(host, "*", dbname, user, secret))
+}
+
+// passwordToPassfile adds the password written in connURL to the password
+// file, so that the URL works without it.
+func passwordToPassfile(connURL string) error {
+	info, err := ParseConnString(connURL)
+	if err != nil || info.Password == "" {
+		return nil
+	}
+	host, _, dbname, user, ok := connParams(connURL)
+	if !ok {
+		return nil
+	}
+	return addPassfileEntry(pgpassLine(host, "*", dbname, user, info.Password))
+}
+
+func pgpassLine(fields ...string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+	for i, f := range fields {
+		fields[i] = escape.Replace(f)
+	}
+	return strings.Join(fields, ":")
+}
+
+func addPassfileEntry(line string) error {
+	passfile.Lock()
+	defer passfile.Unlock()
+	if contains(passfile.entries, line) {
+		return nil
+	}
+	if passfile.path == "" {
+		f, err := os.CreateTemp("", "pgsync-pgpass-*")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		passfile.path = f.Name()
+	}
+	passfile.entries = append(passfile.entries, line)
+
+	content := strings.Join(passfile.entries, "\n") + "\n"
+	// libpq ignores a password file others can read; so does the copy.
+	if info, err := os.Stat(PgpassPath()); err == nil && info.Mode().Perm()&0077 == 0 {
+		if data, err := os.ReadFile(PgpassPath()); err == nil {
+			content += string(data)
+		}
+	}
+	if err := os.WriteFile(passfile.path, []byte(content), 0600); err != nil {
+		return err
+	}
+	return os.Setenv("PGPASSFILE", passfile.path)
+}
+
+// RemovePassfile deletes the password file written for keyring passwords.
+// It is called before pgsync exits.
+func RemovePassfile() {
+	passfile.Lock()
+	defer passfile.Unlock()
+	if passfile.path == "" {
+		return
+	}
+	os.Remove(passfile.path)
+	if userPassfileSet {
+		os.Setenv("PGPASSFILE", userPassfile)
+	} else {
+		os.Unsetenv("PGPASSFILE")
+	}
+	passfile.path, passfile.entries = "", nil
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+const sourcePrivilegesQuery = `SELECT n.nspname || '.' || c.relname,
+	CASE c.relkind WHEN 'S' THEN 'sequence' WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized view' ELSE 'table' END,
+	has_schema_privilege(n.oid, 'USAGE'),
+	CASE WHEN c.relkind = 'S' THEN has_sequence_privilege(c.oid, 'SELECT') ELSE has_table_privilege(c.oid, 'SELECT') END,
+	c.relrowsecurity AND NOT pg_has_role(c.relowner, 'USAGE') AND NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = current_user AND (rolsuper OR rolbypassrls))
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f') AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND n.nspname NOT LIKE 'pg_toast%' AND n.nspname NOT LIKE 'pg_temp%'
+ORDER BY 1;`
+
+const targetPrivilegesQuery = `SELECT n.nspname || '.' || c.relname,
+	pg_has_role(c.relowner, 'USAGE'),
+	has_table_privilege(c.oid, 'INSERT'),
+	has_table_privilege(c.oid, 'UPDATE'),
+	has_table_privilege(c.oid, 'TRUNCATE')
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f') AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND n.nspname NOT LIKE 'pg_toast%' AND n.nspname NOT LIKE 'pg_temp%';`
+
+const targetSchemasQuery = `SELECT nspname, has_schema_privilege(oid, 'CREATE') FROM pg_namespace;`
+
+// sourcePermissionsCheck lists the objects pg_dump would fail on. tables
+// limits the check to the selected tables; empty means the whole database
+// except the excluded schemas.
+func sourcePermissionsCheck(source string, tables, excludeSchemas []string) ([]string, CheckResult) {
+	const name = "Source Permissions"
+	rows, err := queryRows(source, sourcePrivilegesQuery)
+	if err != nil {
+		return nil, CheckResult{Name: name, Status: StatusYellow, Message: "Could not check privileges: " + err.Error()}
+	}
+
+	var objects, noSchema, noSelect, rowSecurity []string
+	for _, r := range rows {
+		if len(r) < 5 || (len(tables) > 0 && !contains(tables, r[0])) {
+			continue
+		}
+		schema, _, _ := strings.Cut(r[0], ".")
+		if contains(excludeSchemas, schema) {
+			continue
+		}
+		objects = append(objects, r[0])
+		switch {
+		case r[2] != "t":
+			if !contains(noSchema, schema) {
+				noSchema = append(noSchema, schema)
+			}
+		case r[3] != "t":
+			noSelect = append(noSelect, r[1]+" "+r[0])
+		case r[4] == "t":
+			rowSecurity = append(rowSecurity, r[0])
+		}
+	}
+
+	var problems []string
+	if len(noSchema) > 0 {
+		problems = append(problems, "no USAGE on schema "+objectList(noSchema))
+	}
+	if len(noSelect) > 0 {
+		problems = append(problems, "no SELECT on "+objectList(noSelect))
+	}
+	if len(rowSecurity) > 0 {
+		problems = append(problems, "row-level security hides rows of "+objectList(rowSecurity)+" (needs owner or BYPASSRLS)")
+	}
+	if len(problems) > 0 {
+		return objects, CheckResult{Name: name, Status: StatusRed, Message: "Dump would fail: " + strings.Join(problems, "; ")}
+	}
+	return objects, CheckResult{Name: name, Status: StatusGreen, Message: fmt.Sprintf("Can read all %d objects", len(objects))}
+}
+
+// targetPermissionsCheck verifies the target user can replace the given
+// objects. A full or schema restore drops them with -c --if-exists, which
+// needs ownership; a data-only load needs the privileges of the strategy.
+func targetPermissionsCheck(target string, objects []string, dataOnly bool, strategy LoadStrategy) CheckResult {
+	const name = "Target Permissions"
+	rows, err := queryRows(target, targetPrivilegesQuery)
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not check privileges: " + err.Error()}
+	}
+	schemaRows, err := queryRows(target, targetSchemasQuery)
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not check privileges: " + err.Error()}
+	}
+	dbCreate, err := queryColumn(target, "SELECT has_database_privilege(current_database(), 'CREATE');")
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not check privileges: " + err.Error()}
+	}
+
+	type targetPrivs struct{ owner, insert, update, truncate bool }
+	existing := make(map[string]targetPrivs)
+	for _, r := range rows {
+		if len(r) == 5 {
+			existing[r[0]] = targetPrivs{owner: r[1] == "t", insert: r[2] == "t", update: r[3] == "t", truncate: r[4] == "t"}
+		}
+	}
+	schemaCreate := make(map[string]bool)
+	for _, r := range schemaRows {
+		if len(r) == 2 {
+			schemaCreate[r[0]] = r[1] == "t"
+		}
+	}
+	canCreateSchema := len(dbCreate) > 0 && dbCreate[0] == "t"
+
+	var problems []string
+	if dataOnly {
+		var noInsert, noTruncate, noUpdate []string
+		for _, obj := range objects {
+			p, ok := existing[obj]
+			if !ok {
+				continue
+			}
+			if !p.insert {
+				noInsert = append(noInsert, obj)
+			}
+			if strategy == LoadTruncate && !p.truncate {
+				noTruncate = append(noTruncate, obj)
+			}
+			if strategy == LoadUpsert && !p.update {
+				noUpdate = append(noUpdate, obj)
+			}
+		}
+		if len(noInsert) > 0 {
+			problems = append(problems, "no INSERT on "+objectList(noInsert))
+		}
+		if len(noTruncate) > 0 {
+			problems = append(problems, "no TRUNCATE on "+objectList(noTruncate))
+		}
+		if len(noUpdate) > 0 {
+			problems = append(problems, "no UPDATE on "+objectList(noUpdate))
+		}
+	} else {
+		var notOwner, noCreate, cannotCreate []string
+		for _, obj := range objects {
+			if p, ok := existing[obj]; ok && !p.owner {
+				notOwner = append(notOwner, obj)
+			}
+			schema, _, _ := strings.Cut(obj, ".")
+			create, exists := schemaCreate[schema]
+			switch {
+			case exists && !create && !contains(noCreate, schema):
+				noCreate = append(noCreate, schema)
+			case !exists && !canCreateSchema && !contains(cannotCreate, schema):
+				cannotCreate = append(cannotCreate, schema)
+			}
+		}
+		if len(notOwner) > 0 {
+			problems = append(problems, "not owner of "+objectList(notOwner)+" (needed to drop them)")
+		}
+		if len(noCreate) > 0 {
+			problems = append(problems, "no CREATE on schema "+objectList(noCreate))
+		}
+		if len(cannotCreate) > 0 {
+			problems = append(problems, "no CREATE on database to add schema "+objectList(cannotCreate))
+		}
+	}
+
+	if len(problems) > 0 {
+		return CheckResult{Name: name, Status: StatusRed, Message: "Restore would fail: " + strings.Join(problems, "; ")}
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: "Can write all objects"}
+}
+
+// permissionChecks checks that the source can be dumped and the target
+// written: a data-only run loads with the strategy's privileges, the others
+// drop and recreate objects.
+func permissionChecks(source, target string, migrationType MigrationType, options MigrationOptions) []CheckResult {
+	objects, srcCheck := sourcePermissionsCheck(source, options.SelectedTables, options.ExcludeSchemas)
+	if objects == nil && srcCheck.Status != StatusGreen {
+		return []CheckResult{srcCheck}
+	}
+	return []CheckResult{srcCheck, targetPermissionsCheck(target, objects, migrationType == DataOnly, options.LoadStrategy)}
+}
+
+func objectList(names []string) string {
+	const max = 5
+	if len(names) <= max {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s (+%d more)", strings.Join(names[:max], ", "), len(names)-max)
+}
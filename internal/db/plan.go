@@ -0,0 +1,336 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pgsync/internal/storage"
+)
+
+type PlanStep struct {
+	Phase       string `json:"phase"`
+	Description string `json:"description"`
+	Command     string `json:"command,omitempty"`
+}
+
+// PlanObject is an archive entry the restore drops and recreates. Exists is
+// only looked up for schemas and relations.
+type PlanObject struct {
+	Type   string `json:"type"`
+	Schema string `json:"schema,omitempty"`
+	Name   string `json:"name"`
+	Exists bool   `json:"exists_on_target"`
+}
+
+type PlanTable struct {
+	Name          string       `json:"name"`
+	EstimatedRows int64        `json:"estimated_rows"`
+	Bytes         int64        `json:"bytes"`
+	Strategy      LoadStrategy `json:"strategy,omitempty"`
+}
+
+type MigrationPlan struct {
+	CreatedAt       time.Time     `json:"created_at"`
+	Source          string        `json:"source"`
+	Target          string        `json:"target"`
+	SourceVersion   string        `json:"source_version"`
+	TargetVersion   string        `json:"target_version"`
+	MigrationType   MigrationType `json:"migration_type"`
+	Provider        string        `json:"provider,omitempty"`
+	Checks          []CheckResult `json:"checks"`
+	Blocked         bool          `json:"blocked"`
+	Steps           []PlanStep    `json:"steps"`
+	Drops           []PlanObject  `json:"drops"`
+	Tables          []PlanTable   `json:"tables"`
+	BackupLocation  string        `json:"backup_location,omitempty"`
+	ExpectedSeconds int64         `json:"expected_seconds"`
+	ExpectedBasis   string        `json:"expected_basis"`
+	Errors          []string      `json:"errors,omitempty"`
+}
+
+// ExistingDrops counts the dropped objects that are already on the target.
+func (p *MigrationPlan) ExistingDrops() int {
+	n := 0
+	for _, o := range p.Drops {
+		if o.Exists {
+			n++
+		}
+	}
+	return n
+}
+
+// Plan runs the pre-flight checks and describes what Migrate would do
+// without writing to the target. For schema migrations the source schema is
+// dumped to a temporary file to list the objects the -c restore drops and
+// recreates.
+func Plan(source, target string, migrationType MigrationType, options MigrationOptions) (*MigrationPlan, error) {
+	t, err := openTunnels(options, source, target)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	est, err := estimate(source, target, migrationType, options, t)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewMigrator(t.url(source), t.url(target), migrationType, options, nil)
+	plan := &MigrationPlan{
+		CreatedAt:     time.Now(),
+		Source:        RedactURL(source),
+		Target:        RedactURL(target),
+		SourceVersion: est.SourceVersion,
+		TargetVersion: est.TargetVersion,
+		MigrationType: migrationType,
+		Checks:        est.Checks,
+		Blocked:       est.HasBlockers(),
+		Drops:         []PlanObject{},
+	}
+	if p, _ := ResolveProvider(options.Provider, target, source); p != nil {
+		plan.Provider = p.Label
+	}
+
+	if migrationType != DataOnly || m.options.AutoBackup {
+		sourceMajor := 0
+		if migrationType != DataOnly {
+			sourceMajor = majorVersion(est.SourceVersion)
+		}
+		if err := m.selectTools(sourceMajor); err != nil {
+			plan.Errors = append(plan.Errors, err.Error())
+		}
+	}
+
+	if plan.Tables, err = m.planTables(); err != nil {
+		plan.Errors = append(plan.Errors, err.Error())
+	}
+	if migrationType != DataOnly {
+		if plan.Drops, err = m.planDrops(); err != nil {
+			plan.Errors = append(plan.Errors, err.Error())
+		}
+	}
+
+	var dataBytes int64
+	for _, t := range plan.Tables {
+		dataBytes += t.Bytes
+	}
+	jobs, _ := strconv.Atoi(m.jobs())
+	prediction := PredictDuration(source, target, migrationType, dataBytes, jobs)
+	plan.ExpectedSeconds = int64(prediction.Total.Seconds())
+	plan.ExpectedBasis = prediction.String()
+
+	if m.options.AutoBackup {
+		plan.BackupLocation = m.store.Location("backup_target_<timestamp>.dump")
+	}
+	plan.Steps = m.planSteps(plan)
+	return plan, nil
+}
+
+const planTablesQuery = `SELECT n.nspname || '.' || c.relname, GREATEST(c.reltuples, 0)::bigint, pg_total_relation_size(c.oid)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+ORDER BY 1;`
+
+func (m *Migrator) planTables() ([]PlanTable, error) {
+	rows, err := queryRows(m.source, planTablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source tables: %w", err)
+	}
+
+	selected := m.options.FilterTables(m.options.SelectedTables)
+	var tables []PlanTable
+	for _, r := range rows {
+		if len(r) < 3 || m.options.ExcludesTable(r[0]) || (len(selected) > 0 && !contains(selected, r[0])) {
+			continue
+		}
+		t := PlanTable{Name: r[0]}
+		t.EstimatedRows, _ = strconv.ParseInt(r[1], 10, 64)
+		t.Bytes, _ = strconv.ParseInt(r[2], 10, 64)
+		if m.migrationType == DataOnly {
+			t.Strategy = m.options.StrategyFor(r[0])
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// Archive entries pg_restore -c drops before recreating them.
+var droppedDescs = []string{
+	"SCHEMA", "EXTENSION", "TYPE", "DOMAIN", "FUNCTION", "PROCEDURE", "AGGREGATE", "OPERATOR",
+	"COLLATION", "CONVERSION", "TABLE", "VIEW", "MATERIALIZED VIEW", "SEQUENCE", "FOREIGN TABLE",
+	"INDEX", "CONSTRAINT", "FK CONSTRAINT", "CHECK CONSTRAINT", "TRIGGER", "EVENT TRIGGER", "RULE",
+	"POLICY", "DEFAULT", "STATISTICS", "CAST", "SERVER", "FOREIGN DATA WRAPPER", "PUBLICATION",
+	"TEXT SEARCH CONFIGURATION", "TEXT SEARCH DICTIONARY",
+}
+
+// Other entry descriptions that span several words.
+var multiWordDescs = []string{
+	"TABLE DATA", "SEQUENCE SET", "SEQUENCE OWNED BY", "DEFAULT ACL", "MATERIALIZED VIEW DATA",
+	"LARGE OBJECT", "BLOB DATA", "DOMAIN CONSTRAINT", "ROW SECURITY", "OPERATOR CLASS",
+	"OPERATOR FAMILY", "PROCEDURAL LANGUAGE", "PUBLICATION TABLE", "PUBLICATION TABLES IN SCHEMA",
+	"SUBSCRIPTION TABLE", "TABLE ATTACH", "INDEX ATTACH", "STATISTICS DATA", "USER MAPPING",
+	"ACCESS METHOD", "TEXT SEARCH PARSER", "TEXT SEARCH TEMPLATE", "SHELL TYPE", "DATABASE PROPERTIES",
+}
+
+type tocEntry struct {
+	Desc   string
+	Schema string
+	Name   string
+}
+
+// parseTOC reads pg_restore -l output. Each entry line looks like
+// "215; 1259 16386 TABLE public users owner".
+func parseTOC(out string) []tocEntry {
+	descs := append(append([]string{}, droppedDescs...), multiWordDescs...)
+	sort.Slice(descs, func(i, j int) bool { return len(descs[i]) > len(descs[j]) })
+
+	var entries []tocEntry
+	for _, line := range strings.Split(out, "\n") {
+		_, entry, ok := strings.Cut(line, "; ")
+		if !ok || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) < 4 {
+			continue
+		}
+		rest := strings.Join(fields[2:], " ")
+		desc := fields[2]
+		for _, d := range descs {
+			if rest == d || strings.HasPrefix(rest, d+" ") {
+				desc = d
+				break
+			}
+		}
+		parts := strings.Fields(strings.TrimPrefix(rest, desc))
+		if len(parts) < 2 {
+			continue
+		}
+		e := tocEntry{Desc: desc, Schema: parts[0], Name: parts[1]}
+		if len(parts) > 3 {
+			e.Name = strings.Join(parts[1:len(parts)-1], " ")
+		}
+		if e.Schema == "-" {
+			e.Schema = ""
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// planDrops dumps the source schema with the run's pg_dump arguments and
+// lists what the restore would drop and recreate on the target.
+func (m *Migrator) planDrops() ([]PlanObject, error) {
+	tmpStore := storage.NewLocal(os.TempDir())
+	key := fmt.Sprintf("pgsync_plan_%d.dump", time.Now().UnixNano())
+	defer tmpStore.Delete(key)
+
+	args := m.dumpArgs()
+	if m.migrationType != SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if _, out, err := DumpToStorage(m.tools.Path("pg_dump"), tmpStore, key, args); err != nil {
+		return []PlanObject{}, fmt.Errorf("schema dump failed: %s", strings.TrimSpace(string(out)))
+	}
+	out, err := exec.Command(m.tools.Path("pg_restore"), "-l", tmpStore.Path(key)).Output()
+	if err != nil {
+		return []PlanObject{}, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	if rows, err := queryColumn(m.target, "SELECT nspname FROM pg_namespace UNION ALL SELECT n.nspname || '.' || c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace;"); err == nil {
+		for _, r := range rows {
+			existing[r] = true
+		}
+	}
+
+	drops := []PlanObject{}
+	for _, e := range parseTOC(string(out)) {
+		if !contains(droppedDescs, e.Desc) {
+			continue
+		}
+		if e.Desc == "EXTENSION" && contains(m.options.SkipExtensions, e.Name) {
+			continue
+		}
+		obj := PlanObject{Type: e.Desc, Schema: e.Schema, Name: e.Name}
+		switch e.Desc {
+		case "SCHEMA":
+			obj.Exists = existing[e.Name]
+		case "TABLE", "VIEW", "MATERIALIZED VIEW", "SEQUENCE", "FOREIGN TABLE", "INDEX":
+			obj.Exists = existing[e.Schema+"."+e.Name]
+		}
+		drops = append(drops, obj)
+	}
+	return drops, nil
+}
+
+func (m *Migrator) planSteps(plan *MigrationPlan) []PlanStep {
+	var steps []PlanStep
+	add := func(phase, desc, command string) {
+		steps = append(steps, PlanStep{Phase: phase, Description: desc, Command: command})
+	}
+	addHooks := func(point HookPoint) {
+		for _, hook := range hooksFor(m.options.Hooks, point) {
+			label := hook.Shell
+			if hook.SQL != "" {
+				label = fmt.Sprintf("psql %s -f %s", hook.On, hook.SQL)
+			}
+			add("hook", fmt.Sprintf("Run %s hook", point), label)
+		}
+	}
+
+	add("connect", "Verify source and target connections", fmt.Sprintf("pg_isready -d %s; pg_isready -d %s", RedactURL(m.source), RedactURL(m.target)))
+	add("lock", "Hold the pgsync advisory lock on the target until the run ends", fmt.Sprintf("SELECT pg_try_advisory_lock(%d, %d)", advisoryLockClass, advisoryLockObj))
+
+	addHooks(HookBeforeBackup)
+	if m.options.AutoBackup {
+		add("backup", "Back up the target for rollback", fmt.Sprintf("%s %s > %s", m.backupTools.Path("pg_dump"), strings.Join(redactArgs(m.backupArgs()), " "), plan.BackupLocation))
+	}
+	addHooks(HookBeforeDump)
+
+	if m.migrationType != DataOnly {
+		add("dump", fmt.Sprintf("Dump the source (%s)", m.migrationType), fmt.Sprintf("%s %s > <temp>.dump", m.tools.Path("pg_dump"), strings.Join(redactArgs(m.dumpArgs()), " ")))
+	}
+	addHooks(HookBeforeRestore)
+	add("activity", "Check sessions on the target (policy: "+string(m.options.OnActivity)+")", "SELECT ... FROM pg_stat_activity")
+
+	if m.migrationType == DataOnly {
+		add("load", fmt.Sprintf("Load %d tables with COPY (default strategy: %s)", len(plan.Tables), m.options.StrategyFor("")), "psql ... COPY ... FROM STDIN")
+	} else {
+		args := append(append([]string{}, restoreFlags...), m.excludeArgs()...)
+		if len(m.options.SkipExtensions) > 0 {
+			args = append(args, "-L", "<list without managed extensions>")
+		}
+		desc := fmt.Sprintf("Drop and recreate %d objects (%d already on the target)", len(plan.Drops), plan.ExistingDrops())
+		add("restore", desc, fmt.Sprintf("%s -d %s -j %s %s <temp>.dump", m.tools.Path("pg_restore"), RedactURL(m.target), m.jobs(), strings.Join(args, " ")))
+	}
+
+	if m.options.SyncSequences && m.migrationType != SchemaOnly {
+		add("sequences", "Advance target sequences to the source values", "SELECT setval(...)")
+	}
+	if m.migrationType != SchemaOnly {
+		switch m.options.Analyze {
+		case AnalyzeFull:
+			add("analyze", "Update planner statistics", "psql ... ANALYZE")
+		case AnalyzeStages:
+			add("analyze", "Update planner statistics in stages", "vacuumdb --analyze-in-stages")
+		}
+		if m.options.RefreshMatViews {
+			add("refresh", "Refresh materialized views", "REFRESH MATERIALIZED VIEW ...")
+		}
+	}
+	addHooks(HookAfterRestore)
+	return steps
+}
+
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, a := range args {
+		redacted[i] = RedactURL(a)
+	}
+	return redacted
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type PoolMode string
+
+const (
+	PoolNone        PoolMode = ""
+	PoolSession     PoolMode = "session"
+	PoolTransaction PoolMode = "transaction"
+	PoolUnknown     PoolMode = "unknown"
+)
+
+type PoolerInfo struct {
+	Kind      string
+	Mode      PoolMode
+	Evidence  []string
+	DirectURL string
+}
+
+func (p *PoolerInfo) Detected() bool {
+	return p.Mode != PoolNone
+}
+
+// What breaks when pg_dump, pg_restore and psql run through a pooler that
+// hands out a different server connection per transaction.
+var transactionPoolingBreaks = []string{
+	"pg_dump exports a snapshot and sets session options before dumping",
+	"pg_restore sets search_path and other session settings, and -j workers expect their own sessions",
+	"data-only loads rely on SET session_replication_role and temp staging tables",
+	"the migration advisory lock is tied to a session",
+}
+
+const poolerProbeAppName = "pgsync_pooler_probe"
+
+// The probe runs in one psql session. Behind a transaction pooler the
+// statements may land on different server backends: the backend pid changes,
+// the prepared statement disappears or application_name is not forwarded.
+const poolerProbeScript = `SELECT 'pid', pg_backend_pid();
+SELECT 'app', current_setting('application_name');
+SELECT 'port', COALESCE(inet_server_port(), 0);
+PREPARE pgsync_probe AS SELECT 1;
+SELECT 'pid', pg_backend_pid();
+EXECUTE pgsync_probe;
+SELECT 'pid', pg_backend_pid();
+DEALLOCATE pgsync_probe;
+`
+
+func DetectPooler(connURL string) *PoolerInfo {
+	return detectPooler(connURL, connURL)
+}
+
+// detectPooler judges connURL by its host and port but probes the session
+// over probeURL, which differs when the connection goes through a tunnel.
+func detectPooler(connURL, probeURL string) *PoolerInfo {
+	info := &PoolerInfo{}
+	conn, err := ParseConnString(connURL)
+	if err != nil {
+		return info
+	}
+	port := conn.Primary().Port
+
+	// A failover list has no single endpoint to judge by host or port or
+	// to rewrite into a direct URL, so only the session probe applies.
+	u, err := url.Parse(conn.URL())
+	if err != nil || len(conn.Hosts) > 1 {
+		u = nil
+	}
+
+	var pooled *PoolerInfo
+	if p := DetectProvider(connURL); p != nil && u != nil {
+		pooled = p.pooler(u, port)
+	}
+	switch {
+	case u == nil:
+	case pooled != nil:
+		info = pooled
+	case port == "6543":
+		info.Kind = "PgBouncer"
+		info.Mode = PoolUnknown
+		info.Evidence = append(info.Evidence, "port 6543 is commonly a transaction pooler")
+		info.DirectURL = withPort(u, "5432")
+	case port == "6432":
+		info.Kind = "PgBouncer"
+		info.Mode = PoolUnknown
+		info.Evidence = append(info.Evidence, "port 6432 is the PgBouncer default")
+		info.DirectURL = withPort(u, "5432")
+	}
+
+	pids, app, serverPort, prepareFailed, err := probeSession(probeURL)
+	if err != nil {
+		return info
+	}
+	// Either signal alone is common without a pooler (port forwarding,
+	// servers that override application_name); together they point to a proxy.
+	portMismatch := u != nil && port != serverPort && serverPort != "0" && serverPort != ""
+	appLost := app != poolerProbeAppName
+	if portMismatch {
+		info.Evidence = append(info.Evidence, fmt.Sprintf("connected to port %s but the server listens on %s", port, serverPort))
+	}
+	if appLost {
+		info.Evidence = append(info.Evidence, "application_name was not forwarded to the server")
+	}
+	if portMismatch && appLost && info.Mode == PoolNone {
+		info.Kind = "proxy"
+		info.Mode = PoolUnknown
+		if u != nil {
+			info.DirectURL = withPort(u, serverPort)
+		}
+	}
+	if prepareFailed || distinct(pids) > 1 {
+		if prepareFailed {
+			info.Evidence = append(info.Evidence, "a prepared statement vanished between statements")
+		} else {
+			info.Evidence = append(info.Evidence, fmt.Sprintf("one client session used %d server backends", distinct(pids)))
+		}
+		if info.Kind == "" {
+			info.Kind = "pooler"
+		}
+		info.Mode = PoolTransaction
+	}
+	return info
+}
+
+func probeSession(connURL string) (pids []string, app, port string, prepareFailed bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "psql", connURL, "-w", "-X", "-A", "-t", "-F", "\t")
+	cmd.Env = append(os.Environ(), "PGAPPNAME="+poolerProbeAppName)
+	cmd.Stdin = strings.NewReader(poolerProbeScript)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, "", "", false, fmt.Errorf("connection timed out")
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		switch {
+		case strings.Contains(line, "pgsync_probe") && strings.Contains(line, "does not exist"):
+			prepareFailed = true
+		case !ok:
+		case key == "pid":
+			pids = append(pids, value)
+		case key == "app":
+			app = value
+		case key == "port":
+			port = value
+		}
+	}
+	if len(pids) == 0 {
+		return nil, "", "", false, fmt.Errorf("probe failed: %s", strings.TrimSpace(string(out)))
+	}
+	return pids, app, port, prepareFailed, nil
+}
+
+func distinct(values []string) int {
+	seen := make(map[string]bool)
+	for _, v := range values {
+		seen[v] = true
+	}
+	return len(seen)
+}
+
+func withPort(u *url.URL, port string) string {
+	direct := *u
+	direct.Host = u.Hostname() + ":" + port
+	if strings.Contains(u.Hostname(), ":") {
+		direct.Host = "[" + u.Hostname() + "]:" + port
+	}
+	return direct.String()
+}
+
+func poolerCheck(label, connURL, probeURL string) (*PoolerInfo, CheckResult) {
+	name := label + " Connection"
+	info := detectPooler(connURL, probeURL)
+	if !info.Detected() {
+		return info, CheckResult{Name: name, Status: StatusGreen, Message: "Direct connection"}
+	}
+
+	msg := fmt.Sprintf("%s detected (%s)", info.Kind, strings.Join(info.Evidence, "; "))
+	advice := " Use a direct connection."
+	if u, err := url.Parse(info.DirectURL); err == nil && info.DirectURL != "" {
+		advice = " Direct URL: " + u.Redacted()
+	}
+
+	switch info.Mode {
+	case PoolTransaction:
+		return info, CheckResult{Name: name, Status: StatusRed, Message: fmt.Sprintf("%s in transaction mode, which breaks migrations: %s.%s", msg, strings.Join(transactionPoolingBreaks, "; "), advice)}
+	case PoolSession:
+		return info, CheckResult{Name: name, Status: StatusYellow, Message: msg + " in session mode; migrations work but parallel jobs each hold a pooled connection." + advice}
+	}
+	return info, CheckResult{Name: name, Status: StatusYellow, Message: msg + "; pool mode unknown. If it pools transactions, pg_dump and pg_restore will fail." + advice}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"pgsync/internal/pkgmgr"
+)
+
+type DurationPrediction struct {
+	Dump    time.Duration
+	Restore time.Duration
+	Total   time.Duration
+	Runs    int // past runs the prediction is based on; 0 means the throughput model
+}
+
+func (p DurationPrediction) String() string {
+	s := fmt.Sprintf("~%s (dump %s, restore %s)", p.Total, p.Dump, p.Restore)
+	switch {
+	case p.Runs == 1:
+		return s + " from the previous run"
+	case p.Runs > 1:
+		return fmt.Sprintf("%s from %d previous runs", s, p.Runs)
+	}
+	return s + " from disk and CPU throughput"
+}
+
+// How many recent successful runs of the same pair are averaged.
+const predictionRuns = 5
+
+// PredictDuration estimates how long a migration of dataBytes takes between
+// source and target. Past successful runs of the same pair and type are
+// scaled to the current size; without history it falls back to a throughput
+// model for this machine.
+func PredictDuration(source, target string, migrationType MigrationType, dataBytes int64, jobs int) DurationPrediction {
+	if p, ok := predictFromHistory(RedactURL(source), RedactURL(target), migrationType, dataBytes); ok {
+		return p
+	}
+	return predictFromThroughput(migrationType, dataBytes, jobs, pkgmgr.GetSystemInfo())
+}
+
+func predictFromHistory(source, target string, migrationType MigrationType, dataBytes int64) (DurationPrediction, bool) {
+	history, err := LoadHistory()
+	if err != nil {
+		return DurationPrediction{}, false
+	}
+
+	var sum DurationPrediction
+	for _, r := range history {
+		if sum.Runs == predictionRuns {
+			break
+		}
+		if r.Status != StatusSuccess || r.Source != source || r.Target != target || r.MigrationType != migrationType || len(r.Phases) == 0 {
+			continue
+		}
+		total, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			continue
+		}
+
+		// Dump and restore scale with the data size; backups, hooks and
+		// maintenance are taken as they were.
+		ratio := 1.0
+		if dataBytes > 0 && r.SourceBytes > 0 {
+			ratio = float64(dataBytes) / float64(r.SourceBytes)
+		}
+		dump := r.phase("Dump")
+		restore := r.phase("Restore") + r.phase("Data load")
+		other := total - dump - restore
+		if other < 0 {
+			other = 0
+		}
+
+		sum.Dump += time.Duration(float64(dump) * ratio)
+		sum.Restore += time.Duration(float64(restore) * ratio)
+		sum.Total += other + time.Duration(float64(dump+restore)*ratio)
+		sum.Runs++
+	}
+	if sum.Runs == 0 {
+		return DurationPrediction{}, false
+	}
+
+	n := time.Duration(sum.Runs)
+	return DurationPrediction{
+		Dump:    (sum.Dump / n).Round(time.Second),
+		Restore: (sum.Restore / n).Round(time.Second),
+		Total:   (sum.Total / n).Round(time.Second),
+		Runs:    sum.Runs,
+	}, true
+}
+
+// Single-stream throughputs in bytes per second of table data.
+var throughputs = map[pkgmgr.DiskType]struct{ dump, restore float64 }{
+	pkgmgr.DiskSSD:     {dump: 80 << 20, restore: 40 << 20},
+	pkgmgr.DiskHDD:     {dump: 30 << 20, restore: 10 << 20},
+	pkgmgr.DiskUnknown: {dump: 40 << 20, restore: 20 << 20},
+}
+
+func predictFromThroughput(migrationType MigrationType, dataBytes int64, jobs int, sys *pkgmgr.SystemInfo) DurationPrediction {
+	if migrationType == SchemaOnly {
+		dataBytes = 0
+	}
+	rate, ok := throughputs[sys.DiskType]
+	if !ok {
+		rate = throughputs[pkgmgr.DiskUnknown]
+	}
+
+	// Restore jobs run in parallel up to the number of cores; index builds
+	// keep the speedup well below linear. Data-only loads copy one table at
+	// a time.
+	if jobs > sys.CPUCores {
+		jobs = sys.CPUCores
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	speedup := 1 + 0.5*float64(jobs-1)
+	if migrationType == DataOnly {
+		speedup = 1
+	}
+
+	var p DurationPrediction
+	if migrationType != DataOnly {
+		p.Dump = seconds(float64(dataBytes) / rate.dump)
+	}
+	p.Restore = seconds(float64(dataBytes) / (rate.restore * speedup))
+	p.Total = p.Dump + p.Restore
+	return p
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+func durationCheck(p DurationPrediction) CheckResult {
+	return CheckResult{Name: "Estimated Duration", Status: StatusGreen, Message: p.String()}
+}
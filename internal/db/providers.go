@@ -0,0 +1,342 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"pgsync/internal/storage"
+)
+
+// Provider is a preset for a managed PostgreSQL service. The platform owns
+// some schemas and extensions in every database; they are left out of dumps
+// and restores so a -c restore never drops or replaces them.
+type Provider struct {
+	Name              string
+	Label             string
+	HostSuffixes      []string
+	ExcludeSchemas    []string
+	ManagedExtensions []string
+	SSLMode           string
+
+	// Pooled endpoints are recognised by PoolerHost in the host name. The
+	// pool mode follows the port; PoolerMode applies to ports not listed.
+	PoolerName  string
+	PoolerHost  string
+	PoolerPorts map[string]PoolMode
+	PoolerMode  PoolMode
+	DirectPort  string
+	DirectHost  func(host string) string
+
+	// A shared pooler serves many projects on one host and routes by the
+	// user name suffix, as in "postgres.<project>". A shared proxy routes
+	// by the endpoint ID, the first label of the host name or, for clients
+	// without SNI, "endpoint=<id>" in the options parameter.
+	TenantInUser     bool
+	TenantInEndpoint bool
+}
+
+var Providers = []*Provider{
+	{
+		Name:         "supabase",
+		Label:        "Supabase",
+		HostSuffixes: []string{".supabase.co", ".supabase.com"},
+		ExcludeSchemas: []string{
+			"auth", "storage", "realtime", "_realtime", "extensions", "graphql", "graphql_public",
+			"vault", "pgsodium", "pgsodium_masks", "pgbouncer", "net", "supabase_functions",
+			"supabase_migrations", "_analytics",
+		},
+		ManagedExtensions: []string{"pg_graphql", "pg_stat_statements", "pgcrypto", "pgjwt", "uuid-ossp", "supabase_vault", "pgsodium", "pg_net"},
+		SSLMode:           "require",
+		PoolerName:        "Supavisor",
+		PoolerHost:        ".pooler.supabase.com",
+		PoolerPorts:       map[string]PoolMode{"6543": PoolTransaction, "5432": PoolSession},
+		DirectPort:        "5432",
+		TenantInUser:      true,
+	},
+	{
+		Name:              "neon",
+		Label:             "Neon",
+		HostSuffixes:      []string{".neon.tech"},
+		ManagedExtensions: []string{"neon", "neon_utils"},
+		SSLMode:           "require",
+		PoolerName:        "Neon pooler",
+		PoolerHost:        "-pooler.",
+		PoolerMode:        PoolTransaction,
+		DirectHost: func(host string) string {
+			return strings.Replace(host, "-pooler.", ".", 1)
+		},
+		TenantInEndpoint: true,
+	},
+	{
+		Name:              "rds",
+		Label:             "Amazon RDS",
+		HostSuffixes:      []string{".rds.amazonaws.com"},
+		ManagedExtensions: []string{"rds_tools"},
+		SSLMode:           "require",
+		PoolerName:        "RDS Proxy",
+		PoolerHost:        ".proxy-",
+		PoolerMode:        PoolTransaction,
+	},
+	// Heroku databases live on plain EC2 host names, which any self-hosted
+	// server on EC2 shares, so the preset is never detected; it has to be
+	// chosen.
+	{
+		Name:              "heroku",
+		Label:             "Heroku Postgres",
+		ExcludeSchemas:    []string{"heroku_ext"},
+		ManagedExtensions: []string{"pg_stat_statements"},
+		SSLMode:           "require",
+	},
+}
+
+// ProviderNames lists the values accepted for MigrationOptions.Provider.
+var ProviderNames = []string{"auto", "none", "supabase", "neon", "rds", "heroku"}
+
+func providerByName(name string) *Provider {
+	for _, p := range Providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// DetectProvider returns the preset whose host suffix matches the URL, or nil.
+func DetectProvider(connURL string) *Provider {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(info.Primary().Host)
+	for _, p := range Providers {
+		for _, suffix := range p.HostSuffixes {
+			if strings.HasSuffix(host, suffix) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveProvider returns the preset called name. "auto" or "" detects it
+// from the given URLs in order, "none" disables presets.
+func ResolveProvider(name string, urls ...string) (*Provider, error) {
+	switch name {
+	case "", "auto":
+		for _, u := range urls {
+			if p := DetectProvider(u); p != nil {
+				return p, nil
+			}
+		}
+		return nil, nil
+	case "none":
+		return nil, nil
+	}
+	if p := providerByName(name); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q (expected one of %s)", name, strings.Join(ProviderNames, ", "))
+}
+
+// WithProviderSSL adds the preset's sslmode to a URL that does not set one.
+func WithProviderSSL(connURL string) string {
+	p := DetectProvider(connURL)
+	if p == nil || p.SSLMode == "" || os.Getenv("PGSSLMODE") != "" {
+		return connURL
+	}
+	info, err := ParseConnString(connURL)
+	if err != nil || info.Params["sslmode"] != "" {
+		return connURL
+	}
+	info.Params["sslmode"] = p.SSLMode
+	return info.URL()
+}
+
+// pooler describes a pooled endpoint of the provider, or returns nil when the
+// URL points at a direct connection.
+func (p *Provider) pooler(u *url.URL, port string) *PoolerInfo {
+	host := strings.ToLower(u.Hostname())
+	if p.PoolerHost == "" || !strings.Contains(host, p.PoolerHost) {
+		return nil
+	}
+	info := &PoolerInfo{Kind: p.PoolerName, Mode: p.PoolerMode}
+	if mode, ok := p.PoolerPorts[port]; ok {
+		info.Mode = mode
+	}
+	info.Evidence = append(info.Evidence, fmt.Sprintf("%s pooler host on port %s", p.Label, port))
+	if info.Mode == PoolTransaction {
+		switch {
+		case p.DirectHost != nil:
+			direct := *u
+			direct.Host = p.DirectHost(u.Host)
+			info.DirectURL = direct.String()
+		case p.DirectPort != "":
+			info.DirectURL = withPort(u, p.DirectPort)
+		}
+	}
+	return info
+}
+
+// WithProvider merges the exclusions of the resolved preset into the options.
+func (o MigrationOptions) WithProvider(urls ...string) MigrationOptions {
+	p, _ := ResolveProvider(o.Provider, urls...)
+	if p == nil {
+		return o
+	}
+	o.ExcludeSchemas = mergeNames(o.ExcludeSchemas, p.ExcludeSchemas)
+	o.SkipExtensions = mergeNames(o.SkipExtensions, p.ManagedExtensions)
+	return o
+}
+
+// ExcludesTable reports whether a schema-qualified table lies in an excluded schema.
+func (o MigrationOptions) ExcludesTable(table string) bool {
+	schema, _, ok := strings.Cut(table, ".")
+	return ok && contains(o.ExcludeSchemas, strings.Trim(schema, `"`))
+}
+
+// FilterTables drops tables in excluded schemas.
+func (o MigrationOptions) FilterTables(tables []string) []string {
+	if len(o.ExcludeSchemas) == 0 {
+		return tables
+	}
+	var kept []string
+	for _, t := range tables {
+		if !o.ExcludesTable(t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func mergeNames(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, name := range b {
+		if !contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+// providerCheck describes what the preset leaves alone. options must already
+// include the preset (see WithProvider).
+func providerCheck(options MigrationOptions, urls ...string) CheckResult {
+	const checkName = "Provider Preset"
+	p, err := ResolveProvider(options.Provider, urls...)
+	switch {
+	case err != nil:
+		return CheckResult{Name: checkName, Status: StatusRed, Message: err.Error()}
+	case p == nil && len(options.ExcludeSchemas) == 0 && len(options.SkipExtensions) == 0:
+		return CheckResult{Name: checkName, Status: StatusGreen, Message: "None; all schemas and extensions are migrated"}
+	}
+
+	label := "Custom"
+	if p != nil {
+		label = p.Label
+	}
+	var parts []string
+	if len(options.ExcludeSchemas) > 0 {
+		parts = append(parts, "skipping schemas "+objectList(options.ExcludeSchemas))
+	}
+	if len(options.SkipExtensions) > 0 {
+		parts = append(parts, "leaving extensions "+objectList(options.SkipExtensions)+" to the platform")
+	}
+	return CheckResult{Name: checkName, Status: StatusGreen, Message: label + ": " + strings.Join(parts, "; ")}
+}
+
+// excludeArgs returns the -N switches for the excluded schemas. pg_dump and
+// pg_restore accept the same flag.
+func (m *Migrator) excludeArgs() []string {
+	var args []string
+	for _, s := range m.options.ExcludeSchemas {
+		args = append(args, "-N", s)
+	}
+	return args
+}
+
+// protectManagedObjects returns the pg_restore switches that keep a -c
+// restore away from excluded schemas and managed extensions.
+func (m *Migrator) protectManagedObjects(tools ClientTools, store storage.Storage, key string) ([]string, func()) {
+	args := m.excludeArgs()
+	if len(m.options.SkipExtensions) == 0 {
+		return args, func() {}
+	}
+	path, ok := storage.LocalPath(store, key)
+	if !ok {
+		m.stats.Warnings = append(m.stats.Warnings, "Managed extensions are not filtered when restoring from object storage: "+strings.Join(m.options.SkipExtensions, ", "))
+		return args, func() {}
+	}
+	listArgs, cleanup, err := m.restoreListArgs(tools, path)
+	if err != nil {
+		m.writeLog("Could not filter managed extensions: %v", err)
+		m.stats.Warnings = append(m.stats.Warnings, "Could not filter managed extensions: "+err.Error())
+	}
+	return append(args, listArgs...), cleanup
+}
+
+// restoreListArgs writes a pg_restore TOC list without the managed
+// extensions and returns the -L switch for it. Schemas are filtered by -N;
+// extensions are not schema objects and would otherwise be dropped by -c.
+// The list can only be read from a local archive.
+func (m *Migrator) restoreListArgs(tools ClientTools, archive string) ([]string, func(), error) {
+	if len(m.options.SkipExtensions) == 0 {
+		return nil, func() {}, nil
+	}
+
+	out, err := exec.Command(tools.Path("pg_restore"), "-l", archive).Output()
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	var list bytes.Buffer
+	var skipped []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := tocExtension(line); ok && contains(m.options.SkipExtensions, name) {
+			if !contains(skipped, name) {
+				skipped = append(skipped, name)
+			}
+			continue
+		}
+		list.WriteString(line + "\n")
+	}
+	if len(skipped) == 0 {
+		return nil, func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "pgsync_restore_*.list")
+	if err != nil {
+		return nil, func() {}, err
+	}
+	defer f.Close()
+	if _, err := f.Write(list.Bytes()); err != nil {
+		os.Remove(f.Name())
+		return nil, func() {}, err
+	}
+	m.writeLog("Leaving managed extensions untouched: %s", strings.Join(skipped, ", "))
+	return []string{"-L", f.Name()}, func() { os.Remove(f.Name()) }, nil
+}
+
+// tocExtension returns the extension an archive TOC line creates or comments
+// on, e.g. "3; 3079 16385 EXTENSION - pgcrypto" or
+// "4350; 0 0 COMMENT - EXTENSION pgcrypto".
+func tocExtension(line string) (string, bool) {
+	_, entry, ok := strings.Cut(line, "; ")
+	if !ok || strings.HasPrefix(line, ";") {
+		return "", false
+	}
+	fields := strings.Fields(entry)
+	switch {
+	case len(fields) >= 5 && fields[2] == "EXTENSION" && fields[3] == "-":
+		return strings.Trim(fields[4], `"`), true
+	case len(fields) >= 6 && fields[2] == "COMMENT" && fields[3] == "-" && fields[4] == "EXTENSION":
+		return strings.Trim(fields[5], `"`), true
+	}
+	return "", false
+}
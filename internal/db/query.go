@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func queryColumn(url, query string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "psql", url, "-w", "-X", "-A", "-t", "-c", query)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("connection timed out")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+
+	var values []string
+	for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if trimmed := strings.TrimSpace(l); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values, nil
+}
+
+func queryRows(url, query string) ([][]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "psql", url, "-w", "-X", "-A", "-t", "-F", "\t", "-c", query)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("connection timed out")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+
+	var rows [][]string
+	for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if strings.TrimSpace(l) != "" {
+			rows = append(rows, strings.Split(l, "\t"))
+		}
+	}
+	return rows, nil
+}
+
+func quoteQualified(table string) string {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		return quoteIdent(table)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quoteIdent(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
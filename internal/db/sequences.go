@@ -0,0 +1,177 @@
+package db
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SequenceSync struct {
+	Name        string `json:"name"`
+	OwnedBy     string `json:"owned_by,omitempty"`
+	SourceValue int64  `json:"source_value"`
+	TargetValue int64  `json:"target_value"`
+	TargetMax   int64  `json:"target_max"`
+	NewValue    int64  `json:"new_value"`
+}
+
+func (s SequenceSync) Drift() int64 {
+	return s.NewValue - s.TargetValue
+}
+
+const sequenceQuery = `SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname),
+	COALESCE(s.last_value, 0),
+	COALESCE((SELECT quote_ident(tn.nspname) || '.' || quote_ident(t.relname) || E'\t' || quote_ident(a.attname)
+		FROM pg_depend d
+		JOIN pg_class t ON t.oid = d.refobjid
+		JOIN pg_namespace tn ON tn.oid = t.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		WHERE d.objid = c.oid AND d.classid = 'pg_class'::regclass AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+		LIMIT 1), E'\t')
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_sequences s ON s.schemaname = n.nspname AND s.sequencename = c.relname
+WHERE c.relkind = 'S' AND n.nspname NOT IN ('information_schema', 'pg_catalog')
+ORDER BY 1;`
+
+type sequenceInfo struct {
+	value  int64
+	table  string
+	column string
+}
+
+func listSequences(url string) (map[string]sequenceInfo, []string, error) {
+	rows, err := queryRows(url, sequenceQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list sequences: %w", err)
+	}
+
+	seqs := make(map[string]sequenceInfo)
+	var names []string
+	for _, row := range rows {
+		if len(row) < 4 {
+			continue
+		}
+		value, _ := strconv.ParseInt(row[1], 10, 64)
+		seqs[row[0]] = sequenceInfo{value: value, table: row[2], column: row[3]}
+		names = append(names, row[0])
+	}
+	return seqs, names, nil
+}
+
+func PlanSequenceSync(source, target string) ([]SequenceSync, error) {
+	srcSeqs, names, err := listSequences(source)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	tgtSeqs, _, err := listSequences(target)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	var maxQueries []string
+	for _, name := range names {
+		tgt, ok := tgtSeqs[name]
+		if ok && tgt.table != "" && tgt.column != "" {
+			maxQueries = append(maxQueries, fmt.Sprintf("SELECT %s, COALESCE(max(%s)::bigint, 0) FROM %s",
+				quoteLiteral(name), tgt.column, tgt.table))
+		}
+	}
+
+	maxValues := make(map[string]int64)
+	if len(maxQueries) > 0 {
+		rows, err := queryRows(target, strings.Join(maxQueries, " UNION ALL ")+";")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read column maximums on target: %w", err)
+		}
+		for _, row := range rows {
+			if len(row) == 2 {
+				maxValues[row[0]], _ = strconv.ParseInt(row[1], 10, 64)
+			}
+		}
+	}
+
+	var plan []SequenceSync
+	for _, name := range names {
+		tgt, ok := tgtSeqs[name]
+		if !ok {
+			continue
+		}
+		src := srcSeqs[name]
+
+		s := SequenceSync{
+			Name:        name,
+			SourceValue: src.value,
+			TargetValue: tgt.value,
+			TargetMax:   maxValues[name],
+		}
+		if tgt.table != "" {
+			s.OwnedBy = tgt.table + "." + tgt.column
+		}
+
+		s.NewValue = s.SourceValue
+		if s.TargetMax > s.NewValue {
+			s.NewValue = s.TargetMax
+		}
+		if s.TargetValue > s.NewValue {
+			s.NewValue = s.TargetValue
+		}
+		plan = append(plan, s)
+	}
+	return plan, nil
+}
+
+func ApplySequenceSync(target string, plan []SequenceSync) ([]SequenceSync, error) {
+	var script strings.Builder
+	script.WriteString("\\set ON_ERROR_STOP on\nBEGIN;\n")
+
+	var applied []SequenceSync
+	for _, s := range plan {
+		if s.Drift() <= 0 || s.NewValue < 1 {
+			continue
+		}
+		fmt.Fprintf(&script, "SELECT setval(%s, %d, true);\n", quoteLiteral(s.Name), s.NewValue)
+		applied = append(applied, s)
+	}
+	script.WriteString("COMMIT;\n")
+
+	if len(applied) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.Command("psql", target, "-w", "-X", "-q")
+	cmd.Stdin = strings.NewReader(script.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("setval failed: %s", strings.TrimSpace(string(out)))
+	}
+	return applied, nil
+}
+
+func SyncSequences(source, target string) ([]SequenceSync, error) {
+	plan, err := PlanSequenceSync(source, target)
+	if err != nil {
+		return nil, err
+	}
+	return ApplySequenceSync(target, plan)
+}
+
+func (m *Migrator) syncSequences(pct float64, step string) {
+	m.sendProgress(pct, step+": Synchronizing sequences...", "SELECT setval(...)")
+	m.writeLog("Synchronizing sequences")
+
+	start := time.Now()
+	applied, err := SyncSequences(m.source, m.target)
+	m.recordPhase("Sequence sync", start)
+	if err != nil {
+		m.writeLog("Sequence sync failed: %v", err)
+		m.stats.Warnings = append(m.stats.Warnings, fmt.Sprintf("Sequence sync failed: %v", err))
+		return
+	}
+
+	for _, s := range applied {
+		m.writeLog("Sequence %s advanced from %d to %d", s.Name, s.TargetValue, s.NewValue)
+	}
+	m.stats.SequenceDrift = applied
+}
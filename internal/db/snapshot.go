@@ -0,0 +1,415 @@
+package db
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"pgsync/internal/storage"
+)
+
+const (
+	snapshotFormatVersion = 1
+	snapshotManifestName  = "manifest.json"
+	snapshotDumpName      = "dump.pgdump"
+)
+
+type Manifest struct {
+	FormatVersion int               `json:"format_version"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Source        string            `json:"source"`
+	SourceVersion string            `json:"source_version"`
+	PgDumpVersion string            `json:"pg_dump_version"`
+	MigrationType MigrationType     `json:"migration_type"`
+	DbSize        string            `json:"db_size"`
+	DbSizeBytes   int64             `json:"db_size_bytes,omitempty"`
+	Extensions    []string          `json:"extensions"`
+	Locale        *DatabaseLocale   `json:"locale,omitempty"`
+	Tables        []string          `json:"tables"`
+	DumpSize      int64             `json:"dump_size"`
+	Checksums     map[string]string `json:"checksums"`
+}
+
+func (m *Migrator) Export(store storage.Storage, key string) (*MigrationStats, error) {
+	startTime := time.Now()
+	var finalErr error
+
+	m.begin()
+	defer m.finish(startTime, m.source, store.Location(key), &finalErr)
+
+	m.writeLog("Starting export %s -> %s (Type: %s)", RedactURL(m.source), store.Location(key), m.migrationType)
+	if err := m.openTunnels(); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.sendProgress(0.1, "Step 1/4: Verifying source connection...", "pg_isready -d "+RedactURL(m.source))
+	if err := CheckConnection(m.source); err != nil {
+		finalErr = fmt.Errorf("source database: %w", err)
+		return &m.stats, finalErr
+	}
+
+	m.measureSource()
+
+	if err := m.selectTools(serverMajor(m.source)); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.sendProgress(0.2, "Step 2/4: Collecting source metadata...", "psql ... SHOW server_version")
+	manifest, err := m.buildManifest()
+	if err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	tmpStore := storage.NewLocal(os.TempDir())
+	dumpKey := fmt.Sprintf("pgsync_export_%d.dump", time.Now().UnixNano())
+	defer tmpStore.Delete(dumpKey)
+
+	if err := m.runHooks(HookBeforeDump, 0.3, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	dumpCmdStr := fmt.Sprintf("pg_dump %s ... (tables: %d)", RedactURL(m.source), len(m.options.SelectedTables))
+	m.sendProgress(0.4, "Step 3/4: Dumping source database...", dumpCmdStr)
+	size, err := m.dumpSource(tmpStore, dumpKey)
+	if err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	dumpPath := tmpStore.Path(dumpKey)
+	sum, err := fileSHA256(dumpPath)
+	if err != nil {
+		finalErr = fmt.Errorf("failed to checksum dump: %w", err)
+		return &m.stats, finalErr
+	}
+	manifest.DumpSize = size
+	manifest.Checksums = map[string]string{snapshotDumpName: sum}
+
+	m.sendProgress(0.8, "Step 4/4: Writing snapshot...", "> "+store.Location(key))
+	if err := writeSnapshot(store, key, manifest, dumpPath); err != nil {
+		m.writeLog("Writing snapshot failed: %v", err)
+		finalErr = fmt.Errorf("failed to write snapshot: %w", err)
+		return &m.stats, finalErr
+	}
+	m.writeLog("Snapshot written to %s (%d bytes dump, sha256 %s)", store.Location(key), size, sum)
+	m.stats.BackupPath = store.Location(key)
+
+	m.sendProgress(1.0, "Export completed!", "")
+
+	return &m.stats, nil
+}
+
+func (m *Migrator) Import(store storage.Storage, key string) (*MigrationStats, error) {
+	startTime := time.Now()
+	var finalErr error
+
+	m.begin()
+	defer m.finish(startTime, store.Location(key), m.target, &finalErr)
+
+	m.writeLog("Starting import %s -> %s", store.Location(key), RedactURL(m.target))
+	if err := CheckProtectedTarget(m.target, m.options); err != nil {
+		m.writeLog("%v", err)
+		finalErr = err
+		return &m.stats, finalErr
+	}
+	if err := m.openTunnels(); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.sendProgress(0.1, "Step 1/4: Verifying target connection...", "pg_isready -d "+RedactURL(m.target))
+	if err := CheckConnection(m.target); err != nil {
+		finalErr = fmt.Errorf("target database: %w", err)
+		return &m.stats, finalErr
+	}
+
+	header, err := ReadManifest(store, key)
+	if err != nil {
+		finalErr = fmt.Errorf("failed to read snapshot manifest: %w", err)
+		return &m.stats, finalErr
+	}
+	if header.MigrationType == DataOnly {
+		if table, ok := m.upsertTable(header.Tables); ok {
+			finalErr = fmt.Errorf("%s: the upsert strategy needs a live source; use truncate or append for imports", table)
+			return &m.stats, finalErr
+		}
+	}
+	// pg_restore must understand the archive format written by the exporting pg_dump.
+	if err := m.selectTools(majorVersion(header.PgDumpVersion)); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.acquireTargetLock(); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.runHooks(HookBeforeBackup, 0.15, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if m.options.AutoBackup {
+		if err := m.backupTarget(0.2, "Step 2/4"); err != nil {
+			finalErr = err
+			return &m.stats, finalErr
+		}
+	}
+
+	tmpStore := storage.NewLocal(os.TempDir())
+	dumpKey := fmt.Sprintf("pgsync_import_%d.dump", time.Now().UnixNano())
+	defer tmpStore.Delete(dumpKey)
+
+	m.sendProgress(0.4, "Step 3/4: Extracting and verifying snapshot...", "< "+store.Location(key))
+	manifest, err := extractSnapshot(store, key, tmpStore.Path(dumpKey))
+	if err != nil {
+		m.writeLog("Extracting snapshot failed: %v", err)
+		finalErr = err
+		return &m.stats, finalErr
+	}
+	m.migrationType = manifest.MigrationType
+	m.stats.MigrationType = manifest.MigrationType
+	m.stats.BytesDumped = manifest.DumpSize
+	if len(manifest.Tables) > 0 {
+		m.stats.TablesMigrated = len(manifest.Tables)
+	}
+
+	if err := m.runHooks(HookBeforeRestore, 0.65, nil); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if err := m.ensureTargetIdle(0.7, "Step 4/4"); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	if m.migrationType == DataOnly {
+		if err := m.truncateTables(m.options.FilterTables(manifest.Tables), 0.7, "Step 4/4"); err != nil {
+			m.writeLog("%v", err)
+			finalErr = err
+			return &m.stats, finalErr
+		}
+	}
+
+	if err := m.restoreTarget(tmpStore, dumpKey, 0.7, "Step 4/4"); err != nil {
+		finalErr = err
+		return &m.stats, finalErr
+	}
+
+	m.afterRestore(0.95, "Step 4/4")
+
+	m.sendProgress(1.0, "Import completed!", "")
+
+	return &m.stats, nil
+}
+
+func (m *Migrator) buildManifest() (*Manifest, error) {
+	ver, err := getPGVersion(m.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source version: %w", err)
+	}
+
+	exts, err := getExtensions(m.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+
+	tables := m.options.SelectedTables
+	if len(tables) == 0 {
+		tables, err = GetTables(m.source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	size, err := getDBSize(m.source)
+	if err != nil {
+		m.stats.Warnings = append(m.stats.Warnings, "Could not record database size in manifest")
+	}
+	sizeBytes, _ := getDBSizeBytes(m.source)
+	locale, err := getDatabaseLocale(m.source)
+	if err != nil {
+		m.stats.Warnings = append(m.stats.Warnings, "Could not record encoding and collation in manifest")
+	}
+
+	return &Manifest{
+		FormatVersion: snapshotFormatVersion,
+		CreatedAt:     time.Now(),
+		Source:        RedactURL(m.givenSource),
+		SourceVersion: ver,
+		PgDumpVersion: clientVersion(m.tools.Path("pg_dump")),
+		MigrationType: m.migrationType,
+		DbSize:        size,
+		DbSizeBytes:   sizeBytes,
+		Extensions:    exts,
+		Locale:        locale,
+		Tables:        tables,
+	}, nil
+}
+
+func ReadManifest(store storage.Storage, key string) (*Manifest, error) {
+	rc, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("not a pgsync snapshot: %w", err)
+	}
+	if hdr.Name != snapshotManifestName {
+		return nil, fmt.Errorf("not a pgsync snapshot: expected %s, found %s", snapshotManifestName, hdr.Name)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+	}
+	if manifest.FormatVersion > snapshotFormatVersion {
+		return nil, fmt.Errorf("snapshot format %d is newer than supported (%d)", manifest.FormatVersion, snapshotFormatVersion)
+	}
+	return &manifest, nil
+}
+
+func writeSnapshot(store storage.Storage, key string, manifest *Manifest, dumpPath string) error {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dump, err := os.Open(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer dump.Close()
+
+	fi, err := dump.Stat()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Name:    snapshotManifestName,
+			Mode:    0644,
+			Size:    int64(len(manifestData)),
+			ModTime: manifest.CreatedAt,
+		})
+		if err == nil {
+			_, err = tw.Write(manifestData)
+		}
+		if err == nil {
+			err = tw.WriteHeader(&tar.Header{
+				Name:    snapshotDumpName,
+				Mode:    0644,
+				Size:    fi.Size(),
+				ModTime: manifest.CreatedAt,
+			})
+		}
+		if err == nil {
+			_, err = io.Copy(tw, dump)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	_, err = store.Put(key, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+func extractSnapshot(store storage.Storage, key, dumpPath string) (*Manifest, error) {
+	rc, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	var manifest *Manifest
+	var dumpSum string
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupt snapshot: %w", err)
+		}
+
+		switch hdr.Name {
+		case snapshotManifestName:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+			}
+		case snapshotDumpName:
+			f, err := os.Create(dumpPath)
+			if err != nil {
+				return nil, err
+			}
+			h := sha256.New()
+			_, err = io.Copy(io.MultiWriter(f, h), tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract dump: %w", err)
+			}
+			dumpSum = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("snapshot has no manifest")
+	}
+	if dumpSum == "" {
+		return nil, fmt.Errorf("snapshot has no dump")
+	}
+	if expected := manifest.Checksums[snapshotDumpName]; expected != dumpSum {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", snapshotDumpName, expected, dumpSum)
+	}
+	return manifest, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func clientVersion(tool string) string {
+	out, err := exec.Command(tool, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"pgsync/internal/storage"
+)
+
+// DumpToStorage runs pg_dump into the archive key. Local archives are written
+// with -f: a custom-format archive written to a pipe has no data offsets,
+// and pg_restore -j needs them to restore tables in parallel.
+func DumpToStorage(pgDump string, store storage.Storage, key string, args []string) (int64, []byte, error) {
+	if path, ok := storage.LocalPath(store, key); ok {
+		return dumpToFile(pgDump, path, args)
+	}
+
+	cmd := exec.Command(pgDump, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, nil, err
+	}
+
+	size, putErr := store.Put(key, stdout)
+	if putErr != nil {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	if putErr != nil {
+		_ = store.Delete(key)
+		return size, stderr.Bytes(), fmt.Errorf("failed to write %s: %w", store.Location(key), putErr)
+	}
+	if waitErr != nil {
+		_ = store.Delete(key)
+		return size, stderr.Bytes(), waitErr
+	}
+	return size, stderr.Bytes(), nil
+}
+
+func dumpToFile(pgDump, path string, args []string) (int64, []byte, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, nil, err
+		}
+	}
+	dumpArgs := append(append([]string{}, args...), "-f", path)
+	out, err := exec.Command(pgDump, dumpArgs...).CombinedOutput()
+	if err != nil {
+		os.Remove(path)
+		return 0, out, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, out, err
+	}
+	return info.Size(), out, nil
+}
+
+func RestoreFromStorage(pgRestore string, store storage.Storage, key string, args []string, jobs string) ([]byte, error) {
+	if path, ok := storage.LocalPath(store, key); ok {
+		restoreArgs := append(append([]string{}, args...), "-j", jobs, path)
+		return exec.Command(pgRestore, restoreArgs...).CombinedOutput()
+	}
+
+	rc, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// pg_restore can only run parallel jobs against a seekable file, so
+	// archives streamed from object storage are restored with a single job.
+	cmd := exec.Command(pgRestore, args...)
+	cmd.Stdin = rc
+	return cmd.CombinedOutput()
+}
+
+// RestoreBackup restores a stored archive, such as a safety backup, into
+// target, replacing the objects it contains. Backups are taken with tools
+// matching the target, so pg_restore is picked for the target version.
+func RestoreBackup(store storage.Storage, key, target string, jobs int) ([]byte, error) {
+	m := &Migrator{target: target, options: MigrationOptions{ParallelJobs: jobs}}
+	m.targetMajor = serverMajor(target)
+	tools, check := SelectClientTools(DetectClientTools(), m.targetMajor, m.targetMajor)
+	if check.Status == StatusRed {
+		return nil, fmt.Errorf("%s", check.Message)
+	}
+	return m.runRestore(tools, store, key, []string{"-w", "-c", "--if-exists", "--no-owner", "--no-privileges"})
+}
+
+// RestoreScriptFromStorage converts the archive to SQL with pg_restore and
+// feeds it to psql, dropping SET statements for settings the target server
+// does not know. Used when the archive was written by a newer pg_dump.
+func RestoreScriptFromStorage(pgRestore string, store storage.Storage, key, target string, args []string, skipSettings []string) ([]byte, error) {
+	restoreArgs := append(append([]string{}, args...), "-f", "-")
+	restore := exec.Command(pgRestore)
+	if path, ok := storage.LocalPath(store, key); ok {
+		restoreArgs = append(restoreArgs, path)
+	} else {
+		rc, err := store.Open(key)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		restore.Stdin = rc
+	}
+	restore.Args = append(restore.Args, restoreArgs...)
+
+	var restoreErr bytes.Buffer
+	restore.Stderr = &restoreErr
+	script, err := restore.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := restore.Start(); err != nil {
+		return nil, err
+	}
+
+	load := exec.Command("psql", target, "-w", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	load.Stdin = filterSettings(script, skipSettings)
+	out, loadErr := load.CombinedOutput()
+	if loadErr != nil {
+		restore.Process.Kill()
+	}
+	waitErr := restore.Wait()
+
+	output := append(restoreErr.Bytes(), out...)
+	if loadErr != nil {
+		return output, loadErr
+	}
+	return output, waitErr
+}
+
+func filterSettings(r io.Reader, settings []string) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			skip := false
+			for _, s := range settings {
+				if strings.HasPrefix(line, "SET "+s+" = ") {
+					skip = true
+					break
+				}
+			}
+			if !skip && line != "" {
+				if _, werr := io.WriteString(pw, line); werr != nil {
+					pw.CloseWithError(werr)
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
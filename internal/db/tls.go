@@ -0,0 +1,275 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pgsync/internal/config"
+)
+
+// SSLModes lists the libpq sslmode values from weakest to strongest.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// ApplyTLS fills in the SSL settings a URL leaves out, from the profile
+// matching its host or else from the endpoint defaults. A production profile
+// defaults to sslmode=verify-full and refuses anything weaker.
+func ApplyTLS(connURL string, defaults config.TLSConfig, profiles []config.Profile) (string, error) {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return "", err
+	}
+	profile := config.ProfileFor(profiles, info.Primary().Host)
+	settings := defaults
+	if profile != nil {
+		settings = mergeTLS(profile.TLS, settings)
+		if profile.Production() && settings.SSLMode == "" {
+			settings.SSLMode = "verify-full"
+		}
+	}
+
+	setTLSParams(info, settings, false)
+	if err := checkTLSParams(info); err != nil {
+		return "", err
+	}
+	if profile.Production() && info.sslMode() != "verify-full" {
+		return "", fmt.Errorf("%s is in the production profile %q, which requires sslmode=verify-full (got %s)", info.Primary().Host, profile.Name, info.sslMode())
+	}
+	return info.URL(), nil
+}
+
+// WithTLS sets the given SSL settings on a URL, replacing those it has.
+// Explicit settings from flags or the TUI go through here before ApplyTLS.
+func WithTLS(connURL string, settings config.TLSConfig) (string, error) {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return "", err
+	}
+	if settings == (config.TLSConfig{}) {
+		return connURL, nil
+	}
+	setTLSParams(info, settings, true)
+	if mode := info.Params["sslmode"]; mode != "" && !contains(SSLModes, mode) {
+		return "", fmt.Errorf("invalid sslmode %q (expected one of %s)", mode, strings.Join(SSLModes, ", "))
+	}
+	return info.URL(), nil
+}
+
+func setTLSParams(info *ConnInfo, settings config.TLSConfig, override bool) {
+	for key, value := range map[string]string{
+		"sslmode":     settings.SSLMode,
+		"sslrootcert": expandHome(settings.RootCert),
+		"sslcert":     expandHome(settings.Cert),
+		"sslkey":      expandHome(settings.Key),
+	} {
+		if value != "" && (override || info.Params[key] == "") {
+			info.Params[key] = value
+		}
+	}
+}
+
+// mergeTLS returns over with the empty settings taken from under.
+func mergeTLS(over, under config.TLSConfig) config.TLSConfig {
+	if over.SSLMode == "" {
+		over.SSLMode = under.SSLMode
+	}
+	if over.RootCert == "" {
+		over.RootCert = under.RootCert
+	}
+	if over.Cert == "" {
+		over.Cert = under.Cert
+	}
+	if over.Key == "" {
+		over.Key = under.Key
+	}
+	return over
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// checkTLSParams catches certificate files that don't exist, which libpq
+// would only report when connecting.
+func checkTLSParams(info *ConnInfo) error {
+	for _, key := range []string{"sslrootcert", "sslcert", "sslkey"} {
+		path := info.Params[key]
+		if path == "" || (key == "sslrootcert" && path == "system") {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// sslMode returns the sslmode libpq uses for the connection.
+func (c *ConnInfo) sslMode() string {
+	if mode := c.Params["sslmode"]; mode != "" {
+		return mode
+	}
+	if mode := os.Getenv("PGSSLMODE"); mode != "" {
+		return mode
+	}
+	return "prefer"
+}
+
+// serverCert describes the certificate a server presented.
+type serverCert struct {
+	Subject   string
+	Issuer    string
+	NotAfter  time.Time
+	VerifyErr error
+}
+
+// fetchServerCert asks the server for TLS the way libpq does and reads its
+// certificate chain without trusting it, then verifies the chain and host
+// name against rootCert ("" or "system" for the system pool). addr is dialled
+// instead of host when set, as libpq does with hostaddr.
+func fetchServerCert(host, addr, port, rootCert string) (*serverCert, error) {
+	if addr == "" {
+		addr = host
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, port), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(cmdTimeout))
+
+	// SSLRequest: length 8 and the magic code 80877103.
+	req := make([]byte, 8)
+	binary.BigEndian.PutUint32(req[0:4], 8)
+	binary.BigEndian.PutUint32(req[4:8], 80877103)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	if answer[0] != 'S' {
+		return nil, fmt.Errorf("server does not accept TLS")
+	}
+
+	// The chain is captured before any client certificate is requested, so
+	// a server that insists on one still shows its own.
+	var chain []*x509.Certificate
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			for _, der := range raw {
+				if cert, err := x509.ParseCertificate(der); err == nil {
+					chain = append(chain, cert)
+				}
+			}
+			return nil
+		},
+	})
+	handshakeErr := tlsConn.Handshake()
+	if len(chain) == 0 {
+		if handshakeErr != nil {
+			return nil, handshakeErr
+		}
+		return nil, fmt.Errorf("server sent no certificate")
+	}
+	tlsConn.Close()
+
+	leaf := chain[0]
+	cert := &serverCert{Subject: leaf.Subject.String(), Issuer: leaf.Issuer.String(), NotAfter: leaf.NotAfter}
+	opts := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if rootCert != "" && rootCert != "system" {
+		pem, err := os.ReadFile(rootCert)
+		if err != nil {
+			cert.VerifyErr = err
+			return cert, nil
+		}
+		opts.Roots = x509.NewCertPool()
+		if !opts.Roots.AppendCertsFromPEM(pem) {
+			cert.VerifyErr = fmt.Errorf("no certificates in %s", rootCert)
+			return cert, nil
+		}
+	}
+	_, cert.VerifyErr = leaf.Verify(opts)
+	return cert, nil
+}
+
+// tlsCheck reports whether the connection is encrypted and which certificate
+// the server presents. An unencrypted production connection is a blocker.
+func tlsCheck(label, connURL string, profiles []config.Profile) CheckResult {
+	name := label + " TLS"
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusYellow, Message: err.Error()}
+	}
+	primary := info.Primary()
+	profile := config.ProfileFor(profiles, primary.Host)
+	mode := info.sslMode()
+
+	rows, err := queryRows(connURL, "SELECT ssl, COALESCE(version, ''), COALESCE(cipher, '') FROM pg_stat_ssl WHERE pid = pg_backend_pid()")
+	if err != nil || len(rows) == 0 || len(rows[0]) < 3 {
+		return CheckResult{Name: name, Status: StatusYellow, Message: "Could not read the connection's TLS state"}
+	}
+
+	if rows[0][0] != "t" {
+		switch {
+		case profile.Production():
+			return CheckResult{Name: name, Status: StatusRed, Message: fmt.Sprintf("Not encrypted, but %s is tagged production by profile %q", primary.Host, profile.Name)}
+		case isLocalHost(connURL):
+			return CheckResult{Name: name, Status: StatusGreen, Message: "Not encrypted (local connection)"}
+		}
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("Not encrypted (sslmode=%s); set sslmode=require or stronger", mode)}
+	}
+
+	msg := fmt.Sprintf("%s %s, sslmode=%s", rows[0][1], rows[0][2], mode)
+	status := StatusGreen
+	if profile.Production() && mode != "verify-full" {
+		status = StatusRed
+		msg += fmt.Sprintf("; production profile %q requires verify-full", profile.Name)
+	}
+
+	cert, err := fetchServerCert(primary.Host, info.Params["hostaddr"], primary.Port, info.Params["sslrootcert"])
+	if err != nil {
+		if status == StatusGreen {
+			status = StatusYellow
+		}
+		return CheckResult{Name: name, Status: status, Message: msg + "; could not read the server certificate: " + err.Error()}
+	}
+	msg += fmt.Sprintf("; certificate %s issued by %s, expires %s", cert.Subject, cert.Issuer, cert.NotAfter.Format("2006-01-02"))
+	switch {
+	case time.Until(cert.NotAfter) < 0:
+		status = StatusRed
+		msg += " (expired)"
+	case time.Until(cert.NotAfter) < 30*24*time.Hour && status == StatusGreen:
+		status = StatusYellow
+		msg += " (expires within 30 days)"
+	}
+	if mode != "verify-ca" && mode != "verify-full" {
+		if status == StatusGreen {
+			status = StatusYellow
+		}
+		if cert.VerifyErr != nil {
+			msg += "; server identity not verified and the certificate does not verify: " + cert.VerifyErr.Error()
+		} else {
+			msg += "; the certificate verifies but sslmode does not check it (use verify-full)"
+		}
+	}
+	return CheckResult{Name: name, Status: status, Message: msg}
+}
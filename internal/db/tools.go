@@ -0,0 +1,220 @@
+package db
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pgsync/internal/storage"
+)
+
+type ClientTools struct {
+	Version string
+	Major   int
+	Dir     string
+}
+
+var clientToolDirs = []string{
+	"/usr/lib/postgresql/*/bin",
+	"/usr/pgsql-*/bin",
+	"/usr/local/pgsql/bin",
+	"/opt/homebrew/opt/postgresql@*/bin",
+	"/usr/local/opt/postgresql@*/bin",
+}
+
+// Settings emitted by pg_dump that older servers reject, keyed by the
+// server major version that introduced them.
+var dumpSettingsSince = map[string]int{
+	"row_security":                        10,
+	"idle_in_transaction_session_timeout": 10,
+	"default_table_access_method":         12,
+	"transaction_timeout":                 17,
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?`)
+
+func (t ClientTools) Path(tool string) string {
+	if t.Dir == "" {
+		return tool
+	}
+	return filepath.Join(t.Dir, tool)
+}
+
+func (t ClientTools) String() string {
+	if t.Dir == "" {
+		return fmt.Sprintf("pg_dump %s (PATH)", t.Version)
+	}
+	return fmt.Sprintf("pg_dump %s (%s)", t.Version, t.Dir)
+}
+
+func majorVersion(version string) int {
+	match := versionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return 0
+	}
+	major, _ := strconv.Atoi(match[1])
+	return major
+}
+
+func DetectClientTools() []ClientTools {
+	var dirs []string
+	if _, err := exec.LookPath("pg_dump"); err == nil {
+		dirs = append(dirs, "")
+	}
+	for _, pattern := range clientToolDirs {
+		matches, _ := filepath.Glob(pattern)
+		dirs = append(dirs, matches...)
+	}
+
+	seen := make(map[string]bool)
+	var found []ClientTools
+	for _, dir := range dirs {
+		t := ClientTools{Dir: dir}
+		pgDump := t.Path("pg_dump")
+		if dir == "" {
+			pgDump, _ = exec.LookPath("pg_dump")
+		}
+		if real, err := filepath.EvalSymlinks(pgDump); err == nil {
+			pgDump = real
+		}
+		if seen[pgDump] {
+			continue
+		}
+		seen[pgDump] = true
+
+		out, err := exec.Command(t.Path("pg_dump"), "--version").Output()
+		if err != nil {
+			continue
+		}
+		line := strings.TrimSpace(string(out))
+		if i := strings.Index(line, ")"); i >= 0 {
+			line = line[i+1:]
+		}
+		t.Version = strings.Fields(line + " ?")[0]
+		t.Major = majorVersion(t.Version)
+		if t.Major == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(t.Path("pg_restore")); err != nil {
+			continue
+		}
+		found = append(found, t)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Major < found[j].Major
+	})
+	return found
+}
+
+// SelectClientTools picks pg_dump/pg_restore for a source and target server
+// major version. pg_dump must be at least as new as the source; ideally the
+// tools are not newer than the target either, so the archive only contains
+// settings the target understands. A targetMajor of 0 means unknown.
+func SelectClientTools(installed []ClientTools, sourceMajor, targetMajor int) (ClientTools, CheckResult) {
+	const name = "Client Tools"
+	if len(installed) == 0 {
+		return ClientTools{}, CheckResult{Name: name, Status: StatusRed, Message: "No pg_dump/pg_restore installation found"}
+	}
+
+	var best *ClientTools
+	for i := range installed {
+		t := installed[i]
+		if t.Major < sourceMajor {
+			continue
+		}
+		if targetMajor > 0 && t.Major <= targetMajor {
+			best = &installed[i]
+			continue
+		}
+		if best == nil {
+			best = &installed[i]
+		}
+		if targetMajor == 0 {
+			break
+		}
+	}
+
+	if best == nil {
+		newest := installed[len(installed)-1]
+		return newest, CheckResult{
+			Name:    name,
+			Status:  StatusRed,
+			Message: fmt.Sprintf("pg_dump %s is older than source server %d; install PostgreSQL %d client tools", newest.Version, sourceMajor, sourceMajor),
+		}
+	}
+
+	if targetMajor > 0 && best.Major > targetMajor {
+		return *best, CheckResult{
+			Name:    name,
+			Status:  StatusYellow,
+			Message: fmt.Sprintf("%s is newer than target %d; settings unknown to the target will be filtered during restore", best, targetMajor),
+		}
+	}
+	return *best, CheckResult{Name: name, Status: StatusGreen, Message: best.String()}
+}
+
+// unsupportedSettings lists the settings a toolMajor pg_dump may emit that a
+// targetMajor server rejects.
+func unsupportedSettings(toolMajor, targetMajor int) []string {
+	var settings []string
+	for setting, since := range dumpSettingsSince {
+		if targetMajor > 0 && targetMajor < since && since <= toolMajor {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+func serverMajor(url string) int {
+	ver, err := getPGVersion(url)
+	if err != nil {
+		return 0
+	}
+	return majorVersion(ver)
+}
+
+// selectTools picks the client tools for this run. minMajor is the oldest
+// pg_dump/pg_restore that can be used: the source server version, or for
+// imports the pg_dump version that wrote the archive. Backups of the target
+// are taken with tools matching the target, which may differ.
+func (m *Migrator) selectTools(minMajor int) error {
+	installed := DetectClientTools()
+	if m.target != "" {
+		m.targetMajor = serverMajor(m.target)
+	}
+
+	tools, check := SelectClientTools(installed, minMajor, m.targetMajor)
+	m.writeLog("Client tools: %s", check.Message)
+	if check.Status == StatusRed {
+		return fmt.Errorf("%s", check.Message)
+	}
+	if check.Status == StatusYellow {
+		m.stats.Warnings = append(m.stats.Warnings, check.Message)
+	}
+	m.tools = tools
+	m.backupTools = tools
+
+	if m.targetMajor > 0 {
+		if backupTools, check := SelectClientTools(installed, m.targetMajor, m.targetMajor); check.Status != StatusRed {
+			m.backupTools = backupTools
+		}
+	}
+	return nil
+}
+
+// runRestore restores an archive into the target with the given tools. When
+// the tools are newer than the target, the archive is converted to SQL and
+// settings the target does not know are filtered out.
+func (m *Migrator) runRestore(tools ClientTools, store storage.Storage, key string, args []string) ([]byte, error) {
+	if skip := unsupportedSettings(tools.Major, m.targetMajor); len(skip) > 0 {
+		m.writeLog("pg_restore %s is newer than target %d; restoring through psql without %s", tools.Version, m.targetMajor, strings.Join(skip, ", "))
+		return RestoreScriptFromStorage(tools.Path("pg_restore"), store, key, m.target, args, skip)
+	}
+	return RestoreFromStorage(tools.Path("pg_restore"), store, key, append([]string{"-d", m.target}, args...), m.jobs())
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectClientTools(t *testing.T) {
+	installed := []ClientTools{
+		{Version: "14.12", Major: 14, Dir: "/usr/lib/postgresql/14/bin"},
+		{Version: "16.4", Major: 16, Dir: "/usr/lib/postgresql/16/bin"},
+		{Version: "17.2", Major: 17, Dir: "/usr/lib/postgresql/17/bin"},
+	}
+	tests := []struct {
+		name                     string
+		installed                []ClientTools
+		sourceMajor, targetMajor int
+		wantMajor                int
+		wantStatus               CheckStatus
+	}{
+		{"nothing installed", nil, 15, 16, 0, StatusRed},
+		{"matches the target", installed, 15, 16, 16, StatusGreen},
+		{"newest not past the target", installed, 14, 17, 17, StatusGreen},
+		{"target older than every tool", installed, 12, 13, 14, StatusYellow},
+		{"source newer than the target", installed, 17, 16, 17, StatusYellow},
+		{"unknown target takes the oldest that fits", installed, 15, 0, 16, StatusGreen},
+		{"source newer than every tool", installed, 18, 18, 17, StatusRed},
+		{"same source and target", installed, 14, 14, 14, StatusGreen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools, check := SelectClientTools(tt.installed, tt.sourceMajor, tt.targetMajor)
+			if tools.Major != tt.wantMajor || check.Status != tt.wantStatus {
+				t.Errorf("SelectClientTools(%d, %d) = %d (%s: %s), want %d (%s)",
+					tt.sourceMajor, tt.targetMajor, tools.Major, check.Status, check.Message, tt.wantMajor, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUnsupportedSettings(t *testing.T) {
+	tests := []struct {
+		toolMajor, targetMajor int
+		want                   []string
+	}{
+		{17, 17, nil},
+		{17, 0, nil},
+		{16, 15, nil},
+		{17, 16, []string{"transaction_timeout"}},
+		{17, 11, []string{"default_table_access_method", "transaction_timeout"}},
+		{16, 9, []string{"default_table_access_method", "idle_in_transaction_session_timeout", "row_security"}},
+	}
+	for _, tt := range tests {
+		if got := unsupportedSettings(tt.toolMajor, tt.targetMajor); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unsupportedSettings(%d, %d) = %q, want %q", tt.toolMajor, tt.targetMajor, got, tt.want)
+		}
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+	}{
+		{"16.4", 16},
+		{"9.6.24", 9},
+		{"17beta1", 17},
+		{"PostgreSQL 15.8 on x86_64-pc-linux-gnu", 15},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := majorVersion(tt.version); got != tt.want {
+			t.Errorf("majorVersion(%q) = %d, want %d", tt.version, got, tt.want)
+		}
+	}
+}
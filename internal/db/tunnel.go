@@ -0,0 +1,336 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"pgsync/internal/config"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Tunnel is a local port forwarded through SSH jump hosts to a database.
+// URL returns the connection rewritten to use it: hostaddr points at the
+// local end while host keeps the real name, so libpq still checks the
+// server certificate against it.
+type Tunnel struct {
+	url        string
+	hops       []string
+	remote     string
+	knownHosts string
+	listener   net.Listener
+	clients    []*ssh.Client
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// OpenTunnel connects to the jump hosts in order and starts forwarding a
+// local port to the database host of connURL.
+func OpenTunnel(connURL string, cfg config.SSHConfig) (*Tunnel, error) {
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Hosts) > 1 {
+		return nil, fmt.Errorf("SSH tunnels need a single database host, not a failover list")
+	}
+	primary := info.Primary()
+	if primary.Host == "" || strings.HasPrefix(primary.Host, "/") {
+		return nil, fmt.Errorf("SSH tunnels need a TCP host, not a local socket")
+	}
+	if len(cfg.Jump) == 0 {
+		return nil, fmt.Errorf("no SSH jump host given")
+	}
+
+	dialHost := primary.Host
+	if addr := info.Params["hostaddr"]; addr != "" {
+		dialHost = addr
+	}
+	t := &Tunnel{hops: cfg.Jump, remote: net.JoinHostPort(dialHost, primary.Port)}
+
+	auth, err := sshAuth(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	t.knownHosts = expandHome(cfg.KnownHosts)
+	if t.knownHosts == "" {
+		t.knownHosts = expandHome("~/.ssh/known_hosts")
+	}
+	hostKeys, err := knownhosts.New(t.knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("known_hosts: %w", err)
+	}
+
+	var client *ssh.Client
+	for _, hop := range cfg.Jump {
+		user, addr := splitHop(hop)
+		clientCfg := &ssh.ClientConfig{User: user, Auth: auth, HostKeyCallback: hostKeys, Timeout: cmdTimeout}
+		if client == nil {
+			client, err = ssh.Dial("tcp", addr, clientCfg)
+		} else {
+			client, err = dialThrough(client, addr, clientCfg)
+		}
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("ssh %s: %w", hop, err)
+		}
+		t.clients = append(t.clients, client)
+	}
+
+	t.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	go t.serve()
+
+	_, localPort, _ := net.SplitHostPort(t.listener.Addr().String())
+	info.Hosts = []HostPort{{Host: primary.Host, Port: localPort}}
+	info.Params["hostaddr"] = "127.0.0.1"
+	t.url = info.URL()
+	return t, nil
+}
+
+func dialThrough(via *ssh.Client, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// splitHop parses [user@]host[:port], defaulting to the local user and
+// port 22.
+func splitHop(hop string) (user, addr string) {
+	user, host, ok := strings.Cut(hop, "@")
+	if !ok {
+		host = user
+		user = os.Getenv("USER")
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	return user, host
+}
+
+// sshAuth offers the keys of a running ssh-agent and the given key file, or
+// the default keys in ~/.ssh. A passphrase-protected key is opened with
+// PGSYNC_SSH_PASSPHRASE.
+func sshAuth(keyFile string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	files := []string{expandHome(keyFile)}
+	if keyFile == "" {
+		files = nil
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			files = append(files, expandHome(filepath.Join("~/.ssh", name)))
+		}
+	}
+	var signers []ssh.Signer
+	for _, f := range files {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			if keyFile != "" {
+				return nil, fmt.Errorf("ssh key: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			passphrase := os.Getenv("PGSYNC_SSH_PASSPHRASE")
+			if passphrase == "" {
+				if keyFile != "" {
+					return nil, fmt.Errorf("ssh key %s is encrypted; set PGSYNC_SSH_PASSPHRASE or load it into ssh-agent", f)
+				}
+				continue
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s: %w", f, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH key found: start ssh-agent or set the key file")
+	}
+	return methods, nil
+}
+
+func (t *Tunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local)
+	}
+}
+
+func (t *Tunnel) forward(local net.Conn) {
+	defer local.Close()
+	remote, err := t.clients[len(t.clients)-1].Dial("tcp", t.remote)
+	if err != nil {
+		t.mu.Lock()
+		t.lastErr = fmt.Errorf("%s: %w", t.remote, err)
+		t.mu.Unlock()
+		return
+	}
+	defer remote.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// URL returns the connection URL that goes through the tunnel.
+func (t *Tunnel) URL() string {
+	return t.url
+}
+
+// Err returns the last error seen while forwarding a connection.
+func (t *Tunnel) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastErr
+}
+
+// String describes the route, for example
+// "admin@bastion:22 → db.internal:5432 (local port 40123)".
+func (t *Tunnel) String() string {
+	local := ""
+	if t.listener != nil {
+		_, port, _ := net.SplitHostPort(t.listener.Addr().String())
+		local = fmt.Sprintf(" (local port %s)", port)
+	}
+	return strings.Join(append(append([]string{}, t.hops...), t.remote), " → ") + local
+}
+
+func (t *Tunnel) Close() {
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	for i := len(t.clients) - 1; i >= 0; i-- {
+		t.clients[i].Close()
+	}
+}
+
+// sshFor returns the tunnel settings of a connection: the explicit ones, or
+// those of the profile matching its host, or nil for a direct connection.
+func sshFor(connURL string, explicit *config.SSHConfig, profiles []config.Profile) *config.SSHConfig {
+	if explicit != nil && len(explicit.Jump) > 0 {
+		return explicit
+	}
+	info, err := ParseConnString(connURL)
+	if err != nil {
+		return nil
+	}
+	if p := config.ProfileFor(profiles, info.Primary().Host); p != nil && p.SSH != nil {
+		return p.SSH
+	}
+	return nil
+}
+
+// WithTunnel calls fn with the URL to connect to, through an SSH tunnel
+// that stays open while fn runs when explicit settings or a matching
+// profile ask for one.
+func WithTunnel(connURL string, explicit *config.SSHConfig, profiles []config.Profile, fn func(url string) error) error {
+	cfg := sshFor(connURL, explicit, profiles)
+	if cfg == nil {
+		return fn(connURL)
+	}
+	t, err := OpenTunnel(connURL, *cfg)
+	if err != nil {
+		return fmt.Errorf("SSH tunnel: %w", err)
+	}
+	defer t.Close()
+	return fn(t.URL())
+}
+
+// tunnels holds the SSH tunnels of a run, keyed by the URL they replace.
+type tunnels map[string]*Tunnel
+
+// openTunnels opens the tunnels the source and target need. Either URL may
+// be empty, as in exports and imports.
+func openTunnels(options MigrationOptions, source, target string) (tunnels, error) {
+	t := tunnels{}
+	for _, e := range []struct {
+		label, url string
+		ssh        *config.SSHConfig
+	}{
+		{"source", source, options.SourceSSH},
+		{"target", target, options.TargetSSH},
+	} {
+		if e.url == "" {
+			continue
+		}
+		cfg := sshFor(e.url, e.ssh, options.Profiles)
+		if cfg == nil {
+			continue
+		}
+		tun, err := OpenTunnel(e.url, *cfg)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("%s SSH tunnel: %w", e.label, err)
+		}
+		t[e.url] = tun
+	}
+	return t, nil
+}
+
+// url returns the URL to hand to psql, pg_dump and pg_restore.
+func (t tunnels) url(connURL string) string {
+	if tun, ok := t[connURL]; ok {
+		return tun.URL()
+	}
+	return connURL
+}
+
+func (t tunnels) Close() {
+	for _, tun := range t {
+		tun.Close()
+	}
+}
+
+// check reports the tunnel of a connection for the pre-flight screen, or
+// false when it connects directly.
+func (t tunnels) check(label, connURL string) (CheckResult, bool) {
+	tun, ok := t[connURL]
+	if !ok {
+		return CheckResult{}, false
+	}
+	name := label + " SSH Tunnel"
+	if err := tun.Err(); err != nil {
+		return CheckResult{Name: name, Status: StatusRed, Message: fmt.Sprintf("%s: forwarding failed: %v", tun, err)}, true
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: fmt.Sprintf("%s; host keys verified against %s", tun, tun.knownHosts)}, true
+}
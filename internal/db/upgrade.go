@@ -0,0 +1,364 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type UpgradeIssue struct {
+	Severity    CheckStatus `json:"severity"`
+	Since       int         `json:"since"`
+	Category    string      `json:"category"`
+	Object      string      `json:"object,omitempty"`
+	Problem     string      `json:"problem"`
+	Remediation string      `json:"remediation"`
+}
+
+type UpgradeReport struct {
+	CheckedAt     time.Time      `json:"checked_at"`
+	SourceVersion string         `json:"source_version"`
+	TargetVersion string         `json:"target_version"`
+	SourceMajor   int            `json:"source_major"`
+	TargetMajor   int            `json:"target_major"`
+	Issues        []UpgradeIssue `json:"issues"`
+	Errors        []string       `json:"errors,omitempty"`
+}
+
+// IsUpgrade reports whether the target runs a newer major version than the source.
+func (r *UpgradeReport) IsUpgrade() bool {
+	return r.SourceMajor > 0 && r.TargetMajor > r.SourceMajor
+}
+
+func (r *UpgradeReport) Count(status CheckStatus) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *UpgradeReport) Check() CheckResult {
+	const name = "Upgrade Assistant"
+	red, yellow := r.Count(StatusRed), r.Count(StatusYellow)
+	switch {
+	case red > 0:
+		return CheckResult{Name: name, Status: StatusRed, Message: fmt.Sprintf("%d blocking, %d warnings for %d -> %d", red, yellow, r.SourceMajor, r.TargetMajor)}
+	case yellow > 0 || len(r.Errors) > 0:
+		return CheckResult{Name: name, Status: StatusYellow, Message: fmt.Sprintf("%d warnings for %d -> %d", yellow, r.SourceMajor, r.TargetMajor)}
+	}
+	return CheckResult{Name: name, Status: StatusGreen, Message: fmt.Sprintf("No known incompatibilities for %d -> %d", r.SourceMajor, r.TargetMajor)}
+}
+
+// upgradeRule describes a change in a major version that affects objects
+// found by a catalog query on the source. The query returns one object name
+// per row and is only run when the change falls inside the upgrade range.
+type upgradeRule struct {
+	since       int
+	beforeOnly  int
+	severity    CheckStatus
+	category    string
+	problem     string
+	remediation string
+	query       string
+}
+
+var upgradeRules = []upgradeRule{
+	{
+		since:       12,
+		beforeOnly:  12,
+		severity:    StatusRed,
+		category:    "Removed feature",
+		problem:     "Table is declared WITH OIDS, which PostgreSQL 12 removed",
+		remediation: "Run ALTER TABLE ... SET WITHOUT OIDS on the source, adding an explicit column if the application reads oid",
+		query:       "SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relhasoids AND n.nspname NOT IN ('pg_catalog', 'information_schema');",
+	},
+	{
+		since:       12,
+		severity:    StatusRed,
+		category:    "Removed type",
+		problem:     "Column uses abstime, reltime or tinterval, which PostgreSQL 12 removed",
+		remediation: "Convert the column to timestamptz or interval on the source before migrating",
+		query:       "SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.' || quote_ident(a.attname) || ' (' || t.typname || ')' FROM pg_attribute a JOIN pg_class c ON c.oid = a.attrelid JOIN pg_namespace n ON n.oid = c.relnamespace JOIN pg_type t ON t.oid = a.atttypid WHERE t.typname IN ('abstime', 'reltime', 'tinterval') AND a.attnum > 0 AND NOT a.attisdropped AND n.nspname NOT IN ('pg_catalog', 'information_schema');",
+	},
+	{
+		since:       14,
+		severity:    StatusRed,
+		category:    "Removed feature",
+		problem:     "Postfix operator; PostgreSQL 14 removed support for postfix operators",
+		remediation: "Replace the operator with a function or a prefix/infix operator and update callers",
+		query:       "SELECT o.oid::regoperator::text FROM pg_operator o JOIN pg_namespace n ON n.oid = o.oprnamespace WHERE o.oprright = 0 AND n.nspname NOT IN ('pg_catalog', 'information_schema');",
+	},
+	{
+		since:       14,
+		severity:    StatusRed,
+		category:    "Changed signature",
+		problem:     "Aggregate uses an array function whose arguments changed from anyarray to anycompatiblearray in PostgreSQL 14",
+		remediation: "Drop the aggregate before migrating and recreate it on the target with anycompatible/anycompatiblearray argument types",
+		query:       "SELECT a.aggfnoid::regprocedure::text FROM pg_aggregate a JOIN pg_proc p ON p.oid = a.aggfnoid JOIN pg_namespace n ON n.oid = p.pronamespace WHERE a.aggtransfn::text IN ('array_append', 'array_prepend', 'array_cat', 'array_position', 'array_positions', 'array_remove', 'array_replace', 'width_bucket') AND n.nspname NOT IN ('pg_catalog', 'information_schema');",
+	},
+	{
+		since:       15,
+		severity:    StatusYellow,
+		category:    "Changed default",
+		problem:     "PostgreSQL 15 no longer grants CREATE on schema public to all users",
+		remediation: "After restore, run GRANT CREATE ON SCHEMA public TO the roles that create objects there",
+		query:       "SELECT 'public' FROM pg_namespace WHERE nspname = 'public' AND has_schema_privilege('public', nspname, 'CREATE');",
+	},
+	{
+		since:       17,
+		severity:    StatusYellow,
+		category:    "Changed behavior",
+		problem:     "PostgreSQL 17 runs functions in expression indexes and materialized views with a restricted search_path during maintenance",
+		remediation: "Schema-qualify object references in the function or attach SET search_path to it",
+		query:       "SELECT DISTINCT p.oid::regprocedure::text FROM pg_depend d JOIN pg_proc p ON p.oid = d.refobjid JOIN pg_namespace n ON n.oid = p.pronamespace LEFT JOIN pg_rewrite rw ON d.classid = 'pg_rewrite'::regclass AND rw.oid = d.objid JOIN pg_class c ON c.oid = CASE WHEN d.classid = 'pg_class'::regclass THEN d.objid ELSE rw.ev_class END WHERE d.refclassid = 'pg_proc'::regclass AND c.relkind IN ('i', 'm') AND p.proconfig IS NULL AND n.nspname NOT IN ('pg_catalog', 'information_schema');",
+	},
+}
+
+// upgradeDefaults are changes that always apply to the upgrade range and
+// need no catalog evidence.
+var upgradeDefaults = []UpgradeIssue{
+	{
+		Severity:    StatusYellow,
+		Since:       14,
+		Category:    "Changed default",
+		Problem:     "password_encryption defaults to scram-sha-256; clients without SCRAM support cannot authenticate with new passwords",
+		Remediation: "Upgrade client drivers or set password_encryption = md5 on the target before setting passwords",
+	},
+	{
+		Severity:    StatusYellow,
+		Since:       12,
+		Category:    "Changed default",
+		Problem:     "jit is enabled by default and can slow down short analytical queries",
+		Remediation: "Set jit = off on the target if query latency regresses",
+	},
+}
+
+// syntaxPattern matches text in user function bodies and view definitions
+// that stops working in a later major version. If context is set, it must
+// match as well.
+type syntaxPattern struct {
+	since       int
+	pattern     *regexp.Regexp
+	context     *regexp.Regexp
+	problem     string
+	remediation string
+}
+
+var syntaxPatterns = []syntaxPattern{
+	{
+		since:       10,
+		pattern:     regexp.MustCompile(`(?i)\bpg_(current|last|switch)_xlog\w*|\bpg_xlog\w*`),
+		problem:     "References pg_*xlog* functions, renamed to pg_*wal* in PostgreSQL 10",
+		remediation: "Replace xlog with wal and location with lsn (e.g. pg_current_wal_lsn())",
+	},
+	{
+		since:       12,
+		pattern:     regexp.MustCompile(`(?i)\b(consrc|adsrc)\b`),
+		problem:     "References pg_constraint.consrc or pg_attrdef.adsrc, removed in PostgreSQL 12",
+		remediation: "Use pg_get_constraintdef(oid) or pg_get_expr(adbin, adrelid) instead",
+	},
+	{
+		since:       12,
+		pattern:     regexp.MustCompile(`(?i)\b(abstime|reltime|tinterval)\b`),
+		problem:     "Uses abstime, reltime or tinterval, removed in PostgreSQL 12",
+		remediation: "Use timestamptz or interval",
+	},
+	{
+		since:       13,
+		pattern:     regexp.MustCompile(`(?i)\b(total|min|max|mean|stddev)_time\b`),
+		context:     regexp.MustCompile(`(?i)\bpg_stat_statements\b`),
+		problem:     "Reads pg_stat_statements timing columns renamed to *_exec_time in PostgreSQL 13",
+		remediation: "Use total_exec_time, mean_exec_time, etc.",
+	},
+	{
+		since:       15,
+		pattern:     regexp.MustCompile(`(?i)\bpg_(start|stop)_backup\b`),
+		problem:     "Calls pg_start_backup/pg_stop_backup, renamed to pg_backup_start/pg_backup_stop in PostgreSQL 15",
+		remediation: "Use pg_backup_start() and pg_backup_stop(); exclusive backup mode no longer exists",
+	},
+	{
+		since:       17,
+		pattern:     regexp.MustCompile(`(?i)\b(checkpoints_timed|checkpoints_req|checkpoint_write_time|checkpoint_sync_time|buffers_checkpoint|buffers_backend\w*)\b`),
+		context:     regexp.MustCompile(`(?i)\bpg_stat_bgwriter\b`),
+		problem:     "Reads checkpoint columns moved from pg_stat_bgwriter to pg_stat_checkpointer in PostgreSQL 17",
+		remediation: "Read checkpoint statistics from pg_stat_checkpointer",
+	},
+}
+
+const userDefinitionsQuery = `SELECT 'function ' || p.oid::regprocedure::text, regexp_replace(p.prosrc, E'[\\n\\r\\t]+', ' ', 'g')
+FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.classid = 'pg_proc'::regclass AND d.deptype = 'e')
+UNION ALL
+SELECT CASE c.relkind WHEN 'm' THEN 'materialized view ' ELSE 'view ' END || quote_ident(n.nspname) || '.' || quote_ident(c.relname),
+	regexp_replace(pg_get_viewdef(c.oid), E'[\\n\\r\\t]+', ' ', 'g')
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('v', 'm') AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = c.oid AND d.classid = 'pg_class'::regclass AND d.deptype = 'e');`
+
+const extensionVersionsQuery = `SELECT e.extname, e.extversion FROM pg_extension e WHERE e.extname <> 'plpgsql' ORDER BY 1;`
+
+const availableExtensionsQuery = `SELECT name, COALESCE(default_version, ''), COALESCE((SELECT string_agg(version, ',') FROM pg_available_extension_versions v WHERE v.name = a.name), '') FROM pg_available_extensions a;`
+
+func CheckUpgrade(source, target string) (*UpgradeReport, error) {
+	sourceVersion, err := getPGVersion(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source version: %w", err)
+	}
+	targetVersion, err := getPGVersion(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target version: %w", err)
+	}
+	return checkUpgrade(source, target, sourceVersion, targetVersion), nil
+}
+
+func checkUpgrade(source, target, sourceVersion, targetVersion string) *UpgradeReport {
+	r := &UpgradeReport{
+		CheckedAt:     time.Now(),
+		SourceVersion: sourceVersion,
+		TargetVersion: targetVersion,
+		SourceMajor:   majorVersion(sourceVersion),
+		TargetMajor:   majorVersion(targetVersion),
+		Issues:        []UpgradeIssue{},
+	}
+	if !r.IsUpgrade() {
+		return r
+	}
+
+	for _, rule := range upgradeRules {
+		if !r.crosses(rule.since) || (rule.beforeOnly > 0 && r.SourceMajor >= rule.beforeOnly) {
+			continue
+		}
+		objects, err := queryColumn(source, rule.query)
+		if err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s check: %v", rule.category, err))
+			continue
+		}
+		for _, obj := range objects {
+			r.Issues = append(r.Issues, UpgradeIssue{
+				Severity:    rule.severity,
+				Since:       rule.since,
+				Category:    rule.category,
+				Object:      obj,
+				Problem:     rule.problem,
+				Remediation: rule.remediation,
+			})
+		}
+	}
+
+	r.checkSyntax(source)
+	r.checkExtensionVersions(source, target)
+
+	for _, d := range upgradeDefaults {
+		if r.crosses(d.Since) {
+			r.Issues = append(r.Issues, d)
+		}
+	}
+	return r
+}
+
+// crosses reports whether a change introduced in version since lies inside
+// the upgrade range.
+func (r *UpgradeReport) crosses(since int) bool {
+	return r.SourceMajor < since && since <= r.TargetMajor
+}
+
+func (r *UpgradeReport) checkSyntax(source string) {
+	var patterns []syntaxPattern
+	for _, p := range syntaxPatterns {
+		if r.crosses(p.since) {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return
+	}
+
+	rows, err := queryRows(source, userDefinitionsQuery)
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("Function and view scan: %v", err))
+		return
+	}
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		body := strings.Join(row[1:], " ")
+		for _, p := range patterns {
+			if !p.pattern.MatchString(body) || (p.context != nil && !p.context.MatchString(body)) {
+				continue
+			}
+			r.Issues = append(r.Issues, UpgradeIssue{
+				Severity:    StatusRed,
+				Since:       p.since,
+				Category:    "Deprecated syntax",
+				Object:      row[0],
+				Problem:     p.problem,
+				Remediation: p.remediation,
+			})
+		}
+	}
+}
+
+func (r *UpgradeReport) checkExtensionVersions(source, target string) {
+	installed, err := queryRows(source, extensionVersionsQuery)
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("Extension versions on source: %v", err))
+		return
+	}
+	available, err := queryRows(target, availableExtensionsQuery)
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("Available extensions on target: %v", err))
+		return
+	}
+
+	type extVersions struct {
+		defaultVersion string
+		versions       []string
+	}
+	onTarget := make(map[string]extVersions)
+	for _, row := range available {
+		if len(row) == 3 {
+			onTarget[row[0]] = extVersions{defaultVersion: row[1], versions: strings.Split(row[2], ",")}
+		}
+	}
+
+	for _, row := range installed {
+		if len(row) != 2 {
+			continue
+		}
+		name, version := row[0], row[1]
+		tgt, ok := onTarget[name]
+		switch {
+		case !ok:
+			r.Issues = append(r.Issues, UpgradeIssue{
+				Severity:    StatusRed,
+				Since:       r.TargetMajor,
+				Category:    "Extension",
+				Object:      name + " " + version,
+				Problem:     fmt.Sprintf("Extension is not available on PostgreSQL %d", r.TargetMajor),
+				Remediation: "Install a build of the extension for the target server, or drop it and its dependent objects on the source",
+			})
+		case tgt.defaultVersion != version:
+			// pg_dump emits CREATE EXTENSION without a version, so the target
+			// installs its default version.
+			msg := fmt.Sprintf("Will be created at version %s on the target (source has %s)", tgt.defaultVersion, version)
+			remediation := fmt.Sprintf("After restore, run ALTER EXTENSION %s UPDATE and check objects that use it", quoteIdent(name))
+			if !contains(tgt.versions, version) {
+				remediation = fmt.Sprintf("Version %s is not installable on the target; check the extension's release notes for changes between %s and %s", version, version, tgt.defaultVersion)
+			}
+			r.Issues = append(r.Issues, UpgradeIssue{
+				Severity:    StatusYellow,
+				Since:       r.TargetMajor,
+				Category:    "Extension",
+				Object:      name + " " + version,
+				Problem:     msg,
+				Remediation: remediation,
+			})
+		}
+	}
+}
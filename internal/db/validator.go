@@ -2,24 +2,165 @@ package db
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"strings"
+
+	"pgsync/internal/config"
 )
 
+// ValidateURL checks a URL or keyword/value connection string: the keywords,
+// hosts, ports, database name and sslmode.
 func ValidateURL(url string) error {
-	if url == "" {
-		return fmt.Errorf("URL cannot be empty")
-	}
+	_, err := ParseConnString(url)
+	return err
+}
 
-	if !strings.HasPrefix(url, "postgres://") && !strings.HasPrefix(url, "postgresql://") {
-		return fmt.Errorf("please enter a valid PostgreSQL URL (must start with postgres:// or postgresql://)")
+// URLsAreDifferent refuses a source and target that reach the same database.
+// Host names are compared as written, after the SSH jump hosts that reach
+// them; only localhost, loopback addresses and socket directories are taken
+// to be one server. Names that share an address are not, since managed
+// services put many databases behind one proxy. On a shared pooler or proxy
+// the project in the user name or the endpoint ID tells databases apart.
+func URLsAreDifferent(source, target string, options MigrationOptions) error {
+	if source == target {
+		return fmt.Errorf("source and target URLs cannot be the same")
+	}
+	src, err := ParseConnString(source)
+	if err != nil {
+		return err
+	}
+	tgt, err := ParseConnString(target)
+	if err != nil {
+		return err
+	}
+	if src.database() != tgt.database() || src.tenant() != tgt.tenant() {
+		return nil
 	}
 
+	srcEndpoints := src.endpoints(jumpChain(sshFor(source, options.SourceSSH, options.Profiles)))
+	for e := range tgt.endpoints(jumpChain(sshFor(target, options.TargetSSH, options.Profiles))) {
+		if srcEndpoints[e] {
+			_, hostPort, _ := strings.Cut(e, " ")
+			return fmt.Errorf("source and target are the same database (%s on %s)", src.database(), strings.Replace(hostPort, "local:", "localhost:", 1))
+		}
+	}
 	return nil
 }
 
-func URLsAreDifferent(source, target string) error {
-	if source == target {
-		return fmt.Errorf("source and target URLs cannot be the same")
+// tenant returns the project a shared pooler or proxy routes the connection
+// to, or "" when the host is not shared.
+func (c *ConnInfo) tenant() string {
+	host := strings.ToLower(c.Primary().Host)
+	for _, p := range Providers {
+		switch {
+		case p.TenantInUser && strings.Contains(host, p.PoolerHost):
+			_, project, _ := strings.Cut(c.User, ".")
+			return project
+		case p.TenantInEndpoint && hasHostSuffix(host, p.HostSuffixes):
+			for _, opt := range strings.Fields(c.Params["options"]) {
+				if id, ok := strings.CutPrefix(opt, "endpoint="); ok {
+					return id
+				}
+			}
+			id, _, _ := strings.Cut(host, ".")
+			return strings.TrimSuffix(id, "-pooler")
+		}
 	}
-	return nil
+	return ""
+}
+
+func hasHostSuffix(host string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// database returns the database name with libpq's defaults.
+func (c *ConnInfo) database() string {
+	if c.DBName != "" {
+		return c.DBName
+	}
+	if db := os.Getenv("PGDATABASE"); db != "" {
+		return db
+	}
+	if c.User != "" {
+		return c.User
+	}
+	if user := os.Getenv("PGUSER"); user != "" {
+		return user
+	}
+	return os.Getenv("USER")
+}
+
+// Primary returns the first host the connection tries, with libpq's
+// defaults applied.
+func (c *ConnInfo) Primary() HostPort {
+	hp := HostPort{}
+	if len(c.Hosts) > 0 {
+		hp = c.Hosts[0]
+	}
+	if hp.Host == "" {
+		hp.Host = os.Getenv("PGHOST")
+	}
+	if hp.Port == "" {
+		hp.Port = os.Getenv("PGPORT")
+	}
+	if hp.Port == "" {
+		hp.Port = "5432"
+	}
+	return hp
+}
+
+// endpoints returns every host:port the connection may reach, prefixed with
+// the jump hosts in between, as in "bastion local:5432".
+func (c *ConnInfo) endpoints(via string) map[string]bool {
+	hosts := c.Hosts
+	if len(hosts) == 0 {
+		hosts = []HostPort{{}}
+	}
+	endpoints := map[string]bool{}
+	for _, hp := range hosts {
+		if hp.Host == "" {
+			hp.Host = os.Getenv("PGHOST")
+		}
+		if hp.Port == "" {
+			hp.Port = c.Primary().Port
+		}
+		endpoints[via+" "+hostKey(hp.Host)+":"+hp.Port] = true
+	}
+	return endpoints
+}
+
+// hostKey normalises a host for comparison. Loopback addresses and socket
+// directories are all "local"; a provider's pooled host becomes the direct
+// host of the same endpoint.
+func hostKey(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == "localhost" || strings.HasPrefix(host, "/") {
+		return "local"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return "local"
+		}
+		return ip.String()
+	}
+	for _, p := range Providers {
+		if p.DirectHost != nil && strings.Contains(host, p.PoolerHost) {
+			return p.DirectHost(host)
+		}
+	}
+	return host
+}
+
+// jumpChain names the SSH jump hosts of a connection, "" for a direct one.
+func jumpChain(cfg *config.SSHConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	return strings.ToLower(strings.Join(cfg.Jump, ","))
 }
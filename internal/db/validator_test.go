@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+
+	"pgsync/internal/config"
+)
+
+func TestURLsAreDifferent(t *testing.T) {
+	t.Setenv("PGHOST", "")
+	t.Setenv("PGPORT", "")
+	t.Setenv("PGDATABASE", "")
+	t.Setenv("PGUSER", "")
+
+	const pooler = "aws-0-eu-central-1.pooler.supabase.com"
+	const neon = "us-east-2.aws.neon.tech"
+	bastion := &config.SSHConfig{Jump: []string{"bastion.example.com"}}
+	other := &config.SSHConfig{Jump: []string{"bastion.other.example.com"}}
+	profiles := []config.Profile{{Hosts: []string{"10.0.*"}, SSH: bastion}}
+
+	tests := []struct {
+		name           string
+		source, target string
+		options        MigrationOptions
+		same           bool
+	}{
+		{"identical", "postgres://app@10.0.0.1/shop", "postgres://app@10.0.0.1/shop", MigrationOptions{}, true},
+		{"other database", "postgres://app@10.0.0.1/shop", "postgres://app@10.0.0.1/shop_copy", MigrationOptions{}, false},
+		{"other host", "postgres://app@10.0.0.1/shop", "postgres://app@10.0.0.2/shop", MigrationOptions{}, false},
+		{"other port", "postgres://app@10.0.0.1:5432/shop", "postgres://app@10.0.0.1:5433/shop", MigrationOptions{}, false},
+		{"default port", "postgres://app@10.0.0.1/shop", "postgres://app@10.0.0.1:5432/shop", MigrationOptions{}, true},
+		{"other user", "postgres://app@10.0.0.1/shop", "postgres://admin@10.0.0.1/shop", MigrationOptions{}, true},
+		{"other password", "postgres://app:a@10.0.0.1/shop", "postgres://app:b@10.0.0.1/shop", MigrationOptions{}, true},
+		{"host name case", "postgres://app@DB.example.com/shop", "postgres://app@db.example.com./shop", MigrationOptions{}, true},
+		{"localhost and loopback", "postgres://app@localhost/shop", "postgres://app@127.0.0.1/shop", MigrationOptions{}, true},
+		{"loopback and socket", "postgres://app@[::1]/shop", "host=/var/run/postgresql dbname=shop user=app", MigrationOptions{}, true},
+		{"url and keywords", "postgres://app@10.0.0.1/shop", "host=10.0.0.1 port=5432 dbname=shop", MigrationOptions{}, true},
+		{"one of several hosts", "postgres://app@10.0.0.1,10.0.0.2/shop", "postgres://app@10.0.0.2/shop", MigrationOptions{}, true},
+		{"database from user", "postgres://shop@10.0.0.1", "postgres://app@10.0.0.1/shop", MigrationOptions{}, true},
+		{"shared pooler, other projects", "postgres://postgres.abcdefgh@" + pooler + ":6543/postgres", "postgres://postgres.ijklmnop@" + pooler + ":6543/postgres", MigrationOptions{}, false},
+		{"shared pooler, same project", "postgres://postgres.abcdefgh@" + pooler + ":6543/postgres", "postgres://postgres.abcdefgh@" + pooler + ":6543/postgres?sslmode=require", MigrationOptions{}, true},
+		{"neon, other endpoints", "postgres://app@ep-cool-darkness-123456." + neon + "/neondb", "postgres://app@ep-quiet-river-654321." + neon + "/neondb", MigrationOptions{}, false},
+		{"neon, pooled and direct", "postgres://app@ep-cool-darkness-123456-pooler." + neon + "/neondb", "postgres://app@ep-cool-darkness-123456." + neon + "/neondb", MigrationOptions{}, true},
+		{"neon, endpoint option", "postgres://app@" + "ep-cool-darkness-123456." + neon + "/neondb", "postgres://app@ep-cool-darkness-123456." + neon + "/neondb?options=endpoint%3Dep-quiet-river-654321", MigrationOptions{}, false},
+		{"same jump host", "postgres://app@10.0.0.5/shop", "postgres://app@10.0.0.5:5432/shop", MigrationOptions{SourceSSH: bastion, TargetSSH: bastion}, true},
+		{"other jump hosts", "postgres://app@10.0.0.5/shop", "postgres://app@10.0.0.5:5432/shop", MigrationOptions{SourceSSH: bastion, TargetSSH: other}, false},
+		{"localhost through a jump host", "postgres://app@localhost/shop", "postgres://app@localhost:5432/shop", MigrationOptions{TargetSSH: bastion}, false},
+		{"jump host from a profile", "postgres://app@10.0.0.5/shop", "postgres://app@10.0.0.5:5432/shop", MigrationOptions{Profiles: profiles, TargetSSH: bastion}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := URLsAreDifferent(tt.source, tt.target, tt.options)
+			if tt.same && err == nil {
+				t.Errorf("URLsAreDifferent(%q, %q) = nil, want an error", tt.source, tt.target)
+			}
+			if !tt.same && err != nil {
+				t.Errorf("URLsAreDifferent(%q, %q) = %v, want nil", tt.source, tt.target, err)
+			}
+		})
+	}
+}
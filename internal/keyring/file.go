@@ -0,0 +1,190 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pgsync/internal/config"
+)
+
+// File keeps passwords in ~/.pgsync/credentials.enc. Only with
+// PGSYNC_KEYRING_PASSPHRASE set are they protected: the AES-GCM key is then
+// derived from the passphrase. Otherwise the key sits beside the file in
+// ~/.pgsync/credentials.key, so the passwords are as safe as a plaintext
+// file readable only by the user, and no safer.
+type File struct {
+	path    string
+	keyPath string
+}
+
+type sealedFile struct {
+	Salt  []byte `json:"salt,omitempty"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+const pbkdf2Rounds = 600000
+
+func NewFile() (*File, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		path:    filepath.Join(dir, "credentials.enc"),
+		keyPath: filepath.Join(dir, "credentials.key"),
+	}, nil
+}
+
+func (f *File) Name() string {
+	if os.Getenv("PGSYNC_KEYRING_PASSPHRASE") != "" {
+		return "passphrase-encrypted file " + f.path
+	}
+	return "file " + f.path + " (readable only by you, not encrypted at rest)"
+}
+
+func (f *File) Get(account string) (string, error) {
+	secrets, _, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (f *File) Set(account, secret string) error {
+	secrets, salt, err := f.load()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return f.save(secrets, salt)
+}
+
+func (f *File) Delete(account string) error {
+	secrets, salt, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[account]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, account)
+	return f.save(secrets, salt)
+}
+
+func (f *File) load() (map[string]string, []byte, error) {
+	secrets := map[string]string{}
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return secrets, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", f.path, err)
+	}
+	gcm, err := f.cipher(sealed.Salt, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt %s (wrong passphrase or key?)", f.path)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", f.path, err)
+	}
+	return secrets, sealed.Salt, nil
+}
+
+func (f *File) save(secrets map[string]string, salt []byte) error {
+	if salt == nil && os.Getenv("PGSYNC_KEYRING_PASSPHRASE") != "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := f.cipher(salt, true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(sealedFile{Salt: salt, Nonce: nonce, Data: gcm.Seal(nil, nonce, plain, nil)})
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// cipher builds the AES-GCM cipher for a file. A salt means the key comes
+// from the passphrase.
+func (f *File) cipher(salt []byte, create bool) (cipher.AEAD, error) {
+	var key []byte
+	if salt != nil {
+		passphrase := os.Getenv("PGSYNC_KEYRING_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("%s is protected by a passphrase; set PGSYNC_KEYRING_PASSPHRASE", f.path)
+		}
+		var err error
+		if key, err = pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Rounds, 32); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if key, err = f.fileKey(create); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (f *File) fileKey(create bool) ([]byte, error) {
+	key, err := os.ReadFile(f.keyPath)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key in %s", f.keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read %s: %w", f.keyPath, err)
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(f.keyPath, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
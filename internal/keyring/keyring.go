@@ -0,0 +1,51 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Keyring stores database passwords outside config files and history.
+type Keyring interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+	Name() string
+}
+
+var ErrNotFound = errors.New("no password stored")
+
+// New returns the configured backend. "auto" prefers the freedesktop Secret
+// Service and falls back to the credentials file; "off" returns nil.
+func New(backend string) (Keyring, error) {
+	switch backend {
+	case "", "auto":
+		if SecretServiceAvailable() {
+			return &SecretService{}, nil
+		}
+		return NewFile()
+	case "secret-service":
+		if !SecretServiceAvailable() {
+			return nil, fmt.Errorf("secret service is not available (needs secret-tool and a D-Bus session)")
+		}
+		return &SecretService{}, nil
+	case "file":
+		return NewFile()
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown keyring %q (expected auto, secret-service, file or off)", backend)
+	}
+}
+
+// SecretServiceAvailable reports whether secret-tool can reach a keyring
+// daemon such as GNOME Keyring or KWallet.
+func SecretServiceAvailable() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
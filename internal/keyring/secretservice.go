@@ -0,0 +1,50 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SecretService keeps passwords in the desktop keyring through secret-tool
+// from libsecret.
+type SecretService struct{}
+
+func (s *SecretService) Name() string { return "secret service" }
+
+func attributes(account string) []string {
+	return []string{"service", "pgsync", "account", account}
+}
+
+func (s *SecretService) Get(account string) (string, error) {
+	out, err := exec.Command("secret-tool", append([]string{"lookup"}, attributes(account)...)...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret-tool lookup failed: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (s *SecretService) Set(account, secret string) error {
+	args := append([]string{"store", "--label", "pgsync: " + account}, attributes(account)...)
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (s *SecretService) Delete(account string) error {
+	if err := exec.Command("secret-tool", append([]string{"clear"}, attributes(account)...)...).Run(); err != nil {
+		return fmt.Errorf("secret-tool clear failed: %w", err)
+	}
+	return nil
+}
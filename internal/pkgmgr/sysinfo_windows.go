@@ -0,0 +1,52 @@
+//go:build windows
+
+package pkgmgr
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+func FreeSpace(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return 0, volumeError("free space of", path, err)
+	}
+	return free, nil
+}
+
+// DeviceID identifies the volume holding path by its serial number.
+func DeviceID(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	root := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return 0, volumeError("volume of", path, err)
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return 0, volumeError("volume of", path, err)
+	}
+	return uint64(serial), nil
+}
+
+// volumeError wraps a failed volume query. Only volumes that cannot answer
+// it, such as some network shares, report errors.ErrUnsupported; a missing
+// path or denied access is a real error.
+func volumeError(what, path string, err error) error {
+	switch {
+	case errors.Is(err, windows.ERROR_NOT_SUPPORTED),
+		errors.Is(err, windows.ERROR_INVALID_FUNCTION),
+		errors.Is(err, windows.ERROR_CALL_NOT_IMPLEMENTED):
+		return fmt.Errorf("%s %s: %w", what, path, errors.ErrUnsupported)
+	}
+	return fmt.Errorf("%s %s: %w", what, path, err)
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (l *Local) Dir() string {
+	if l.dir == "" {
+		return "."
+	}
+	return l.dir
+}
+
+func (l *Local) Path(key string) string {
+	if l.dir == "" {
+		return key
+	}
+	return filepath.Join(l.dir, key)
+}
+
+func (l *Local) Put(key string, r io.Reader) (int64, error) {
+	path := l.Path(key)
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	return os.Open(l.Path(key))
+}
+
+func (l *Local) Delete(key string) error {
+	err := os.Remove(l.Path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) Location(key string) string {
+	return l.Path(key)
+}
@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"pgsync/internal/config"
+)
+
+const (
+	s3MinPartSize    = 16 << 20
+	s3MaxPartSize    = 5 << 30
+	s3MaxParts       = 10000
+	s3PartsPerSize   = 1000
+	s3UnsignedBody   = "UNSIGNED-PAYLOAD"
+	s3DefaultRegion  = "us-east-1"
+	s3SigningService = "s3"
+)
+
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires a bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 storage requires access_key and secret_key")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	endpoint := cfg.Endpoint
+	pathStyle := cfg.PathStyle
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	} else {
+		pathStyle = true
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	return &S3{
+		endpoint:  u,
+		region:    region,
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		pathStyle: pathStyle,
+		client:    &http.Client{},
+	}, nil
+}
+
+func (s *S3) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *S3) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.objectKey(key))
+}
+
+// s3PartSize returns the size of part partNum of a multipart upload. Parts
+// double in size every thousand parts, so the 10,000 parts S3 allows hold
+// terabytes while small dumps still upload in 16 MiB pieces.
+func s3PartSize(partNum int) int {
+	size := int64(s3MinPartSize) << ((partNum - 1) / s3PartsPerSize)
+	if size > s3MaxPartSize {
+		size = s3MaxPartSize
+	}
+	return int(size)
+}
+
+// Put streams r to the bucket, in a multipart upload once it outgrows the
+// first part. Each part is spooled to a temporary file rather than held in
+// memory, since late parts of a large dump grow to gigabytes.
+func (s *S3) Put(key string, r io.Reader) (int64, error) {
+	spool, err := os.CreateTemp("", "pgsync-s3-part-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	// readPart spools the next part of up to size bytes; a short part is
+	// the last one.
+	readPart := func(size int) (*io.SectionReader, error) {
+		if err := spool.Truncate(0); err != nil {
+			return nil, err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		n, err := io.CopyN(spool, r, int64(size))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return io.NewSectionReader(spool, 0, n), nil
+	}
+
+	part, err := readPart(s3PartSize(1))
+	if err != nil {
+		return 0, err
+	}
+	if part.Size() < int64(s3PartSize(1)) {
+		if err := s.putObject(key, part); err != nil {
+			return 0, err
+		}
+		return part.Size(), nil
+	}
+
+	uploadID, err := s.createMultipartUpload(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var parts []s3CompletedPart
+	var total int64
+	for partNum := 1; ; partNum++ {
+		etag, err := s.uploadPart(key, uploadID, partNum, part)
+		if err != nil {
+			s.abortMultipartUpload(key, uploadID)
+			return total, err
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: partNum, ETag: etag})
+		total += part.Size()
+
+		part, err = readPart(s3PartSize(partNum + 1))
+		if err != nil {
+			s.abortMultipartUpload(key, uploadID)
+			return total, err
+		}
+		if part.Size() == 0 {
+			break
+		}
+		if partNum == s3MaxParts {
+			s.abortMultipartUpload(key, uploadID)
+			return total, fmt.Errorf("%s exceeds the %d parts of an s3 multipart upload", s.Location(key), s3MaxParts)
+		}
+	}
+
+	if err := s.completeMultipartUpload(key, uploadID, parts); err != nil {
+		s.abortMultipartUpload(key, uploadID)
+		return total, err
+	}
+
+	return total, nil
+}
+
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) putObject(key string, body io.ReadSeeker) error {
+	resp, err := s.do(http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type s3InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *S3) createMultipartUpload(key string) (string, error) {
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var res s3InitiateResult
+	if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("failed to parse multipart upload response: %w", err)
+	}
+	if res.UploadID == "" {
+		return "", fmt.Errorf("s3 did not return an upload id")
+	}
+	return res.UploadID, nil
+}
+
+func (s *S3) uploadPart(key, uploadID string, partNum int, body io.ReadSeeker) (string, error) {
+	query := url.Values{
+		"partNumber": {fmt.Sprintf("%d", partNum)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := s.do(http.MethodPut, key, query, body)
+	if err != nil {
+		return "", fmt.Errorf("upload of part %d failed: %w", partNum, err)
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *S3) completeMultipartUpload(key, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(s3CompleteUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 can report a failed completion with a 200 status and an error body.
+	data, _ := io.ReadAll(resp.Body)
+	var e s3Error
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3 error %s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+func (s *S3) abortMultipartUpload(key, uploadID string) {
+	resp, err := s.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// do sends a signed request. The body is sent with its length, read by
+// seeking to its end.
+func (s *S3) do(method, key string, query url.Values, body io.ReadSeeker) (*http.Response, error) {
+	host := s.endpoint.Host
+	path := "/" + s3Escape(s.objectKey(key), false)
+	if s.pathStyle {
+		path = "/" + s3Escape(s.bucket, true) + path
+	} else {
+		host = s.bucket + "." + host
+	}
+
+	rawQuery := s3CanonicalQuery(query)
+	target := s.endpoint.Scheme + "://" + host + path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	var reader io.Reader
+	var size int64
+	if body != nil {
+		var err error
+		if size, err = body.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			reader = body
+		}
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	s.sign(req, host, path, rawQuery, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var e s3Error
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", method, s.Location(key), e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s", method, s.Location(key), resp.Status)
+	}
+	return resp, nil
+}
+
+func (s *S3) sign(req *http.Request, host, path, rawQuery string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Host = host
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3UnsignedBody)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\n" +
+		"x-amz-content-sha256:" + s3UnsignedBody + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		rawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		s3UnsignedBody,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, s3SigningService, "aws4_request"}, "/")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s3SigningService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
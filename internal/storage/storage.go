@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"pgsync/internal/config"
+)
+
+type Storage interface {
+	Put(key string, r io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	Location(key string) string
+}
+
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocal(cfg.Dir), nil
+	case "s3":
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q (expected local or s3)", cfg.Type)
+	}
+}
+
+func LocalPath(s Storage, key string) (string, bool) {
+	if l, ok := s.(*Local); ok {
+		return l.Path(key), true
+	}
+	return "", false
+}
+
+func IsLocal(s Storage) bool {
+	_, ok := s.(*Local)
+	return ok
+}
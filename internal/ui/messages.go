@@ -1,10 +1,17 @@
 package ui
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"time"
 
+	"pgsync/internal/config"
 	"pgsync/internal/db"
 	"pgsync/internal/pkgmgr"
+	"pgsync/internal/storage"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -21,21 +28,47 @@ type MigrationCompleteMsg struct{}
 type MigrationErrorMsg string
 type TickMsg time.Time
 
+// EstimationMsg and TablesMsg carry the sequence number of the request they
+// answer; replies to a request that has since been replaced are dropped.
 type EstimationMsg struct {
+	Seq    int
 	Result *db.EstimationResult
 	Err    error
 }
 
 type TablesMsg struct {
+	Seq    int
 	Tables []string
 	Err    error
 }
 
+type PlanMsg struct {
+	Plan *db.MigrationPlan
+	Err  error
+}
+
+type ReportSavedMsg struct {
+	Path string
+	Err  error
+}
+
 type HistoryMsg struct {
 	History []db.MigrationRecord
 	Err     error
 }
 
+type LogTailMsg struct {
+	Lines []string
+	Err   error
+}
+
+type LogClosedMsg struct{ Err error }
+
+type BackupRestoredMsg struct {
+	Output string
+	Err    error
+}
+
 func checkDepsCmd() tea.Msg {
 	err := db.CheckDependencies()
 	var missing []string
@@ -71,17 +104,24 @@ func tickCmd() tea.Cmd {
 	})
 }
 
-func estimateCmd(source, target string) tea.Cmd {
+func estimateCmd(seq int, source, target string, migrationType db.MigrationType, options db.MigrationOptions) tea.Cmd {
 	return func() tea.Msg {
-		res, err := db.Estimate(source, target)
-		return EstimationMsg{Result: res, Err: err}
+		res, err := db.Estimate(source, target, migrationType, options)
+		return EstimationMsg{Seq: seq, Result: res, Err: err}
 	}
 }
 
-func fetchTablesCmd(url string) tea.Cmd {
+func fetchTablesCmd(seq int, url string, ssh *config.SSHConfig, profiles []config.Profile) tea.Cmd {
 	return func() tea.Msg {
-		tables, err := db.GetTables(url)
-		return TablesMsg{Tables: tables, Err: err}
+		var tables []string
+		err := db.WithTunnel(url, ssh, profiles, func(url string) (err error) {
+			tables, err = db.GetTables(url)
+			return err
+		})
+		if err != nil {
+			return TablesMsg{Seq: seq, Err: db.NewConnError("source", err)}
+		}
+		return TablesMsg{Seq: seq, Tables: tables}
 	}
 }
 
@@ -91,3 +131,56 @@ func loadHistoryCmd() tea.Cmd {
 		return HistoryMsg{History: hist, Err: err}
 	}
 }
+
+func logTailCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		lines, err := db.LogTail(path, 15)
+		return LogTailMsg{Lines: lines, Err: err}
+	}
+}
+
+// openLogCmd shows a run log in $PAGER, suspending the TUI meanwhile.
+func openLogCmd(path string) tea.Cmd {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	args := strings.Fields(pager)
+	c := exec.Command(args[0], append(args[1:], path)...)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		return LogClosedMsg{Err: err}
+	})
+}
+
+// restoreBackupCmd restores into a target from history, tunnelling through
+// SSH when a profile matches its host.
+func restoreBackupCmd(store storage.Storage, key, target string, jobs int, profiles []config.Profile) tea.Cmd {
+	return func() tea.Msg {
+		var out []byte
+		err := db.WithTunnel(target, nil, profiles, func(target string) (err error) {
+			out, err = db.RestoreBackup(store, key, target, jobs)
+			return err
+		})
+		return BackupRestoredMsg{Output: string(out), Err: err}
+	}
+}
+
+func planCmd(source, target string, migrationType db.MigrationType, options db.MigrationOptions) tea.Cmd {
+	return func() tea.Msg {
+		plan, err := db.Plan(source, target, migrationType, options)
+		return PlanMsg{Plan: plan, Err: err}
+	}
+}
+
+// saveReportCmd writes a report as JSON to pgsync_<name>_<timestamp>.json in
+// the working directory.
+func saveReportCmd(name string, report interface{}) tea.Cmd {
+	return func() tea.Msg {
+		path := fmt.Sprintf("pgsync_%s_%s.json", name, time.Now().Format("20060102_150405"))
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+		return ReportSavedMsg{Path: path, Err: err}
+	}
+}
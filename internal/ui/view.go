@@ -14,18 +14,34 @@ func (m Model) View() string {
 		return m.viewIntro()
 	case StateHistory:
 		return m.viewHistory()
+	case StateHistoryDetail:
+		return m.viewHistoryDetail()
 	case StateSourceURL:
 		return m.viewSourceURL()
 	case StateTargetURL:
 		return m.viewTargetURL()
+	case StateTLS:
+		return m.viewTLS()
 	case StateEstimation:
 		return m.viewEstimation()
+	case StateUpgrade:
+		return m.viewUpgrade()
 	case StateTableSelect:
 		return m.viewTableSelect()
 	case StateOptions:
 		return m.viewOptions()
+	case StateTableStrategy:
+		return m.viewTableStrategy()
 	case StateMigrationType:
 		return m.viewMigrationType()
+	case StateReview:
+		return m.viewReview()
+	case StatePlan:
+		return m.viewPlan()
+	case StateConfirmRestore:
+		return m.viewConfirmRestore()
+	case StateConfirmTerminate:
+		return m.viewConfirmTerminate()
 	case StateMigrating:
 		return m.viewProgress()
 	case StateComplete:
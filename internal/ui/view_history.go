@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"pgsync/internal/db"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func (m Model) viewHistory() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render("Migration History"))
+	b.WriteString("\n\n")
+
+	status := "all"
+	if m.historyFilter.Status != "" {
+		status = string(m.historyFilter.Status)
+	}
+	target := "any"
+	if m.historyFilter.Target != "" {
+		target = m.historyFilter.Target
+	}
+	b.WriteString(HintStyle.Render(fmt.Sprintf("   Status: %s • Target: %s • Date: %s", status, target, historyRanges[m.historyRange].label)))
+	b.WriteString("\n\n")
+
+	if m.historyEditing {
+		b.WriteString("   Filter by target: " + m.textInput.View() + "\n\n")
+		b.WriteString(HelpStyle.Render("enter apply • esc cancel"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+
+	records := m.filteredHistory()
+	if len(records) == 0 {
+		if len(m.history) == 0 {
+			b.WriteString("   No history found.\n")
+		} else {
+			b.WriteString("   No runs match the filters.\n")
+		}
+	} else {
+		start := m.scrollOffset
+		end := start + 10
+		if end > len(records) {
+			end = len(records)
+		}
+		for i := start; i < end; i++ {
+			h := records[i]
+			statusColor := "2"
+			if h.Status == db.StatusFailed {
+				statusColor = "1"
+			}
+			cursor := " "
+			style := UnselectedItemStyle
+			if m.cursor == i {
+				cursor = ">"
+				style = SelectedItemStyle
+			}
+			b.WriteString(style.Render(fmt.Sprintf("%s %s  %-10s %8s  %s",
+				cursor,
+				h.Timestamp.Format("2006-01-02 15:04"),
+				migrationTypeLabelShort(h.MigrationType),
+				h.Duration,
+				h.Target,
+			)))
+			b.WriteString(" " + lipgloss.NewStyle().Foreground(lipgloss.Color(statusColor)).Render(string(h.Status)) + "\n")
+		}
+		if len(records) > 10 {
+			b.WriteString(fmt.Sprintf("\n   Showing %d-%d of %d\n", start+1, end, len(records)))
+		}
+	}
+
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render("↑/↓ select • enter details • s status • / target • d date range • c clear • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func migrationTypeLabelShort(t db.MigrationType) string {
+	switch t {
+	case db.SchemaOnly:
+		return "schema"
+	case db.DataOnly:
+		return "data"
+	}
+	return "full"
+}
+
+func (m Model) viewHistoryDetail() string {
+	var b strings.Builder
+	rec := m.historyRecord
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render(fmt.Sprintf("Run of %s", rec.Timestamp.Format("2006-01-02 15:04:05"))))
+	b.WriteString("\n\n")
+
+	const pageSize = 18
+	lines := m.historyLines()
+	end := m.scrollOffset + pageSize
+	if end > len(lines) {
+		end = len(lines)
+	}
+	for _, line := range lines[m.scrollOffset:end] {
+		b.WriteString(line + "\n")
+	}
+	if len(lines) > pageSize {
+		b.WriteString(fmt.Sprintf("\n   Showing lines %d-%d of %d\n", m.scrollOffset+1, end, len(lines)))
+	}
+
+	switch {
+	case m.restoring:
+		b.WriteString("\n   " + m.spinner.View() + " Restoring " + rec.BackupPath + "...\n")
+	case m.restoreTarget != "":
+		b.WriteString("\n   " + WarningStyle.Render(fmt.Sprintf("Restore %s into %s? Objects in the backup are dropped and recreated.", rec.BackupPath, db.RedactURL(m.restoreTarget))) + "\n")
+		if p := db.TargetProfile(m.restoreTarget, m.options.Profiles); p.IsProtected() {
+			name := db.DatabaseName(m.restoreTarget)
+			b.WriteString("\n   Environment: " + p.Label() + "\n")
+			b.WriteString(fmt.Sprintf("   Type %s to confirm: %s\n", name, m.confirmInput.View()))
+			if m.errorMsg != "" {
+				b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+			}
+			b.WriteString("\n")
+			b.WriteString(HelpStyle.Render("enter restore • esc cancel"))
+			b.WriteString("\n\n")
+			return b.String()
+		}
+		b.WriteString("\n")
+		b.WriteString(HelpStyle.Render("y restore • n cancel"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+	if m.successMsg != "" {
+		b.WriteString("\n   " + SuccessStyle.Render("✓ "+m.successMsg) + "\n")
+	}
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
+	help := "↑/↓ scroll • r re-run"
+	if rec.BackupKey != "" {
+		help += " • b restore backup"
+	}
+	if rec.LogPath != "" {
+		help += " • l open log"
+	}
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render(help + " • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func (m Model) historyLines() []string {
+	rec := m.historyRecord
+	var lines []string
+
+	status := SuccessStyle.Render(string(rec.Status))
+	if rec.Status == db.StatusFailed {
+		status = ErrorStyle.Render(string(rec.Status))
+	}
+	lines = append(lines,
+		"   Status:         "+status,
+		"   Mode:           "+migrationTypeLabel(rec.MigrationType),
+		"   Source:         "+rec.Source,
+		"   Target:         "+rec.Target,
+		"   Duration:       "+rec.Duration,
+	)
+	if rec.SourceBytes > 0 {
+		lines = append(lines, fmt.Sprintf("   Data:           %s (%s dumped)", db.FormatBytes(rec.SourceBytes), db.FormatBytes(rec.BytesDumped)))
+	}
+	if len(rec.SelectedTables) > 0 {
+		lines = append(lines, fmt.Sprintf("   Tables:         %d selected", len(rec.SelectedTables)))
+	} else if rec.TableCount > 0 {
+		lines = append(lines, fmt.Sprintf("   Tables:         %d", rec.TableCount))
+	}
+	if rec.BackupPath != "" {
+		lines = append(lines, "   Backup:         "+rec.BackupPath)
+	}
+	if rec.LogPath != "" {
+		lines = append(lines, "   Log:            "+rec.LogPath)
+	}
+	if rec.PgsyncVersion != "" || rec.PgDumpVersion != "" {
+		lines = append(lines, fmt.Sprintf("   Versions:       pgsync %s, pg_dump %s", rec.PgsyncVersion, rec.PgDumpVersion))
+	}
+	if rec.Error != "" {
+		lines = append(lines, "", "   "+ErrorStyle.Render("✗ "+rec.Error))
+	}
+	if rec.DidRollback {
+		if rec.RollbackSuccess {
+			lines = append(lines, "   "+WarningStyle.Render("⟲ Rollback was performed (target restored)"))
+		} else {
+			lines = append(lines, "   "+ErrorStyle.Render("⟲ Rollback attempted but failed"))
+		}
+	}
+
+	if o := rec.Options; o != nil {
+		lines = append(lines, "", "   "+PromptStyle.Render("Options"))
+		lines = append(lines, fmt.Sprintf("     • Parallel jobs: %d, backup: %t, on activity: %s", o.ParallelJobs, o.AutoBackup, o.OnActivity))
+		if rec.MigrationType == db.DataOnly {
+			lines = append(lines, fmt.Sprintf("     • Load: %s, cascade: %t, disable triggers: %t", o.LoadStrategy, o.TruncateCascade, o.DisableTriggers))
+		}
+		lines = append(lines, fmt.Sprintf("     • Sync sequences: %t, analyze: %s, refresh matviews: %t", o.SyncSequences, o.Analyze, o.RefreshMatViews))
+		if o.Provider != "" {
+			lines = append(lines, "     • Provider: "+o.Provider)
+		}
+	}
+
+	if len(rec.TableResults) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render("Rows loaded"))
+		for _, r := range rec.TableResults {
+			lines = append(lines, fmt.Sprintf("     • %-40s %-9s %d rows", r.Table, r.Strategy, r.Rows))
+		}
+	}
+	if len(rec.SequenceDrift) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render("Sequences advanced"))
+		for _, s := range rec.SequenceDrift {
+			lines = append(lines, fmt.Sprintf("     • %-40s %d → %d (+%d)", s.Name, s.TargetValue, s.NewValue, s.Drift()))
+		}
+	}
+	if len(rec.Phases) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render("Timings"))
+		for _, p := range rec.Phases {
+			lines = append(lines, fmt.Sprintf("     • %-40s %s", p.Name, p.Duration))
+		}
+	}
+	if len(rec.Warnings) > 0 {
+		lines = append(lines, "", "   "+WarningStyle.Render("⚠ Warnings"))
+		for _, w := range rec.Warnings {
+			lines = append(lines, "     • "+w)
+		}
+	}
+	if len(m.logTail) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render("Log tail"))
+		for _, l := range m.logTail {
+			lines = append(lines, "   "+HintStyle.Render(l))
+		}
+	}
+	return lines
+}
@@ -1,7 +1,12 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
+	"unicode"
+
+	"pgsync/internal/config"
+	"pgsync/internal/db"
 )
 
 func (m Model) viewSourceURL() string {
@@ -9,15 +14,17 @@ func (m Model) viewSourceURL() string {
 	b.WriteString("\n")
 	b.WriteString(PromptStyle.Render("Enter source database URL:"))
 	b.WriteString("\n\n")
-	b.WriteString(m.textInput.View())
+	b.WriteString(m.urlInputView())
 	b.WriteString("\n")
+	b.WriteString(m.credentialHint())
+	b.WriteString(m.tlsHint())
 	if m.errorMsg != "" {
 		b.WriteString("\n")
 		b.WriteString(ErrorMessageStyle.Render("✗ " + m.errorMsg))
 		b.WriteString("\n")
 	}
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("enter to continue"))
+	b.WriteString(HelpStyle.Render(m.urlHelp()))
 	b.WriteString("\n\n")
 	return b.String()
 }
@@ -25,17 +32,230 @@ func (m Model) viewSourceURL() string {
 func (m Model) viewTargetURL() string {
 	var b strings.Builder
 	b.WriteString("\n")
-	b.WriteString(PromptStyle.Render("Enter target database URL:"))
+	title := "Enter target database URL:"
+	if m.restorePending {
+		title = "Restore backup into database URL:"
+	}
+	b.WriteString(PromptStyle.Render(title))
 	b.WriteString("\n\n")
-	b.WriteString(m.textInput.View())
+	b.WriteString(m.urlInputView())
 	b.WriteString("\n")
+	b.WriteString(m.credentialHint())
+	b.WriteString(m.tlsHint())
 	if m.errorMsg != "" {
 		b.WriteString("\n")
 		b.WriteString(ErrorMessageStyle.Render("✗ " + m.errorMsg))
 		b.WriteString("\n")
 	}
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("enter to continue"))
+	b.WriteString(HelpStyle.Render(m.urlHelp()))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// urlInputView renders the URL input with the password replaced by dots.
+func (m Model) urlInputView() string {
+	value := m.textInput.Value()
+	start, end, ok := passwordSpan(value)
+	if !ok {
+		return m.textInput.View()
+	}
+	runes := []rune(value)
+	for i := start; i < end; i++ {
+		runes[i] = '•'
+	}
+	masked := m.textInput
+	masked.SetValue(string(runes))
+	masked.SetCursor(m.textInput.Position())
+	return masked.View()
+}
+
+// passwordSpan returns the rune range of the password in a typed URL. Until
+// the @ is typed, text after the user's colon counts as a password unless it
+// looks like a port.
+func passwordSpan(value string) (int, int, bool) {
+	i := strings.Index(value, "://")
+	if i < 0 {
+		return 0, 0, false
+	}
+	authStart := len([]rune(value[:i+3]))
+	rest := []rune(value)[authStart:]
+
+	end := -1
+	for j := len(rest) - 1; j >= 0; j-- {
+		if rest[j] == '@' {
+			end = j
+			break
+		}
+	}
+	typing := end < 0
+	if typing {
+		end = len(rest)
+		for j, r := range rest {
+			if r == '/' || r == '?' || r == '#' {
+				end = j
+				break
+			}
+		}
+	}
+
+	colon := -1
+	for j := 0; j < end; j++ {
+		if rest[j] == ':' {
+			colon = j
+			break
+		}
+	}
+	if colon < 0 || colon+1 >= end {
+		return 0, 0, false
+	}
+	if typing && strings.IndexFunc(string(rest[colon+1:end]), func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+		return 0, 0, false
+	}
+	return authStart + colon + 1, authStart + end, true
+}
+
+// credentialHint tells where the connection details or password will come
+// from when they are not typed in. The keyring is only consulted on enter,
+// since reading it can be slow.
+func (m Model) credentialHint() string {
+	value := strings.TrimSpace(m.textInput.Value())
+	if value == "" {
+		if env := db.EnvURL(); env != "" {
+			return "\n" + HintStyle.Render("   Leave empty to use the PG* environment: "+db.RedactURL(env)) + "\n"
+		}
+		return ""
+	}
+	if db.ValidateURL(value) != nil {
+		return ""
+	}
+
+	switch src := db.PasswordSource(value, nil); {
+	case src == "URL":
+		return ""
+	case src != "":
+		return "\n" + HintStyle.Render("   Password from "+src) + "\n"
+	case m.keyring != nil:
+		return "\n" + HintStyle.Render("   No password typed; one remembered in the "+m.keyring.Name()+" is used if present") + "\n"
+	}
+	return "\n" + HintStyle.Render("   No password found; the server must allow login without one") + "\n"
+}
+
+func (m Model) urlHelp() string {
+	help := "enter to continue"
+	if len(m.services) > 0 {
+		help += " • tab next service"
+	}
+	help += " • ctrl+t TLS/SSH"
+	if m.keyring != nil {
+		if m.rememberPassword {
+			help += " • ctrl+s remember password: on"
+		} else {
+			help += " • ctrl+s remember password: off"
+		}
+	}
+	switch {
+	case m.restorePending:
+		help += " • esc cancel"
+	case m.reviewing:
+		help += " • esc back to review"
+	default:
+		help += " • esc back"
+	}
+	return help
+}
+
+// tlsHint shows the TLS and SSH settings chosen with ctrl+t and warns when the host
+// belongs to a production profile.
+func (m Model) tlsHint() string {
+	var b strings.Builder
+	if summary := tlsSummary(m.endpointTLS()); summary != "" {
+		b.WriteString("\n" + HintStyle.Render("   TLS: "+summary) + "\n")
+	}
+	if ssh := m.endpointSSH(); ssh != nil {
+		if b.Len() == 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(HintStyle.Render("   SSH: via "+strings.Join(ssh.Jump, " → ")) + "\n")
+	}
+	info, err := db.ParseConnString(m.textInput.Value())
+	if err != nil || m.config == nil {
+		return b.String()
+	}
+	p := config.ProfileFor(m.config.Profiles, info.Primary().Host)
+	if p != nil && m.state == StateTargetURL {
+		line := "   Environment: " + p.Label()
+		if p.IsProtected() {
+			line += "; the database name must be typed to confirm and a backup is required"
+		}
+		b.WriteString("\n" + HintStyle.Render(line) + "\n")
+	}
+	if p.Production() {
+		b.WriteString("\n" + WarningStyle.Render(fmt.Sprintf("   ⚠ Production profile %q: TLS with sslmode=verify-full is required", p.Name)) + "\n")
+	}
+	if p != nil && p.SSH != nil && m.endpointSSH() == nil {
+		b.WriteString(HintStyle.Render(fmt.Sprintf("   SSH: via %s (profile %q)", strings.Join(p.SSH.Jump, " → "), p.Name)) + "\n")
+	}
+	return b.String()
+}
+
+func tlsSummary(tls config.TLSConfig) string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{"sslmode", tls.SSLMode}, {"sslrootcert", tls.RootCert}, {"sslcert", tls.Cert}, {"sslkey", tls.Key},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (m Model) viewTLS() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	endpoint := "target"
+	defaults := m.config.TLS.Target
+	if m.tlsReturn == StateSourceURL {
+		endpoint = "source"
+		defaults = m.config.TLS.Source
+	}
+	b.WriteString(PromptStyle.Render("TLS and SSH settings for the " + endpoint + " database"))
+	b.WriteString("\n\n")
+
+	mode := m.tlsMode
+	if mode == "" {
+		mode = "default"
+		if defaults.SSLMode != "" {
+			mode += " (" + defaults.SSLMode + ")"
+		}
+	}
+	modeInfo := "sslmode:     " + mode
+	if m.cursor == 0 {
+		modeInfo += "  (←/→ to change)"
+	}
+	b.WriteString(m.optionRow(0, modeInfo))
+	b.WriteString("\n")
+	for i, label := range []string{"sslrootcert:", "sslcert:    ", "sslkey:     "} {
+		b.WriteString(m.optionRow(i+1, label+" "+m.tlsInputs[i].View()))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for i, label := range []string{"ssh jump:   ", "ssh key:    ", "known_hosts:"} {
+		b.WriteString(m.optionRow(i+4, label+" "+m.tlsInputs[i+3].View()))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HintStyle.Render("   These override the URL. Empty fields fall back to the URL, then the matching"))
+	b.WriteString("\n")
+	b.WriteString(HintStyle.Render("   profile, then tls." + endpoint + " in config.json. sslrootcert=system uses the system CAs."))
+	b.WriteString("\n")
+	b.WriteString(HintStyle.Render("   SSH jump hosts tunnel the connection (comma-separated for several hops); empty uses"))
+	b.WriteString("\n")
+	b.WriteString(HintStyle.Render("   the matching profile's ssh settings. Keys come from ssh-agent or ~/.ssh/id_*."))
+	b.WriteString("\n\n")
+	b.WriteString(HelpStyle.Render("↑/↓ move • ←/→ sslmode • enter save • esc cancel"))
 	b.WriteString("\n\n")
 	return b.String()
 }
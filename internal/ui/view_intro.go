@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"strings"
 
-	"pgsync/internal/db"
-
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -53,36 +51,3 @@ func (m Model) viewIntro() string {
 
 	return b.String()
 }
-
-func (m Model) viewHistory() string {
-	var b strings.Builder
-	b.WriteString("\n")
-	b.WriteString(PromptStyle.Render("Migration History"))
-	b.WriteString("\n\n")
-
-	if len(m.history) == 0 {
-		b.WriteString("   No history found.\n")
-	} else {
-		for i, h := range m.history {
-			if i >= 10 {
-				break
-			}
-			statusColor := "2"
-			if h.Status == db.StatusFailed {
-				statusColor = "1"
-			}
-
-			b.WriteString(fmt.Sprintf("   %s  %s -> %s  (%s)\n",
-				lipgloss.NewStyle().Foreground(lipgloss.Color(statusColor)).Render(string(h.Status)),
-				h.Timestamp.Format("2006-01-02 15:04"),
-				h.Target,
-				h.Duration,
-			))
-		}
-	}
-
-	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("esc to back"))
-	b.WriteString("\n\n")
-	return b.String()
-}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"pgsync/internal/db"
 
@@ -53,11 +54,27 @@ func (m Model) viewProgress() string {
 	}
 
 	b.WriteString(HintStyle.Render(fmt.Sprintf("%s %s", pctStr, funMsg)))
+	b.WriteString("\n")
+	b.WriteString(HintStyle.Render(m.countdown()))
 	b.WriteString("\n\n")
 
 	return b.String()
 }
 
+func (m Model) countdown() string {
+	elapsed := time.Since(m.startedAt).Round(time.Second)
+	total := m.prediction.Total
+	switch {
+	case m.startedAt.IsZero():
+		return ""
+	case total <= 0:
+		return fmt.Sprintf("Elapsed %s", elapsed)
+	case elapsed > total:
+		return fmt.Sprintf("Elapsed %s • taking longer than the estimated %s", elapsed, total)
+	}
+	return fmt.Sprintf("Elapsed %s • about %s left", elapsed, total-elapsed)
+}
+
 func (m Model) viewComplete() string {
 	var b strings.Builder
 	b.WriteString("\n")
@@ -70,14 +87,7 @@ func (m Model) viewComplete() string {
 	b.WriteString("\n\n")
 
 	if m.finalStats != nil {
-		modeStr := "Full (Schema + Data)"
-		switch m.finalStats.MigrationType {
-		case db.SchemaOnly:
-			modeStr = "Schema Only"
-		case db.DataOnly:
-			modeStr = "Data Only"
-		}
-		b.WriteString(fmt.Sprintf("   Mode:           %s\n", modeStr))
+		b.WriteString(fmt.Sprintf("   Mode:           %s\n", migrationTypeLabel(m.finalStats.MigrationType)))
 
 		if m.finalStats.TablesMigrated > 0 {
 			b.WriteString(fmt.Sprintf("   Tables:         %d migrated\n", m.finalStats.TablesMigrated))
@@ -91,6 +101,33 @@ func (m Model) viewComplete() string {
 			b.WriteString(fmt.Sprintf("   Backup:         %s\n", m.finalStats.BackupPath))
 		}
 
+		if len(m.finalStats.TableResults) > 0 {
+			b.WriteString("\n")
+			b.WriteString(PromptStyle.Render("   Rows loaded:"))
+			b.WriteString("\n")
+			for _, r := range m.finalStats.TableResults {
+				b.WriteString(fmt.Sprintf("     • %-40s %-9s %d rows\n", r.Table, r.Strategy, r.Rows))
+			}
+		}
+
+		if len(m.finalStats.SequenceDrift) > 0 {
+			b.WriteString("\n")
+			b.WriteString(PromptStyle.Render("   Sequences advanced:"))
+			b.WriteString("\n")
+			for _, s := range m.finalStats.SequenceDrift {
+				b.WriteString(fmt.Sprintf("     • %-40s %d → %d (+%d)\n", s.Name, s.TargetValue, s.NewValue, s.Drift()))
+			}
+		}
+
+		if len(m.finalStats.Phases) > 0 {
+			b.WriteString("\n")
+			b.WriteString(PromptStyle.Render("   Timings:"))
+			b.WriteString("\n")
+			for _, p := range m.finalStats.Phases {
+				b.WriteString(fmt.Sprintf("     • %-40s %s\n", p.Name, p.Duration))
+			}
+		}
+
 		if len(m.finalStats.Warnings) > 0 {
 			b.WriteString("\n")
 			b.WriteString(WarningStyle.Render("   ⚠ Warnings:"))
@@ -141,6 +178,18 @@ func (m Model) viewError() string {
 			b.WriteString(fmt.Sprintf("   Backup:   %s\n", m.finalStats.BackupPath))
 		}
 
+		if len(m.finalStats.TableResults) > 0 {
+			b.WriteString("\n")
+			for _, r := range m.finalStats.TableResults {
+				line := fmt.Sprintf("   %-40s %-9s %d rows", r.Table, r.Strategy, r.Rows)
+				if r.Err != "" {
+					b.WriteString(ErrorStyle.Render(line+"  ✗ "+r.Err) + "\n")
+				} else {
+					b.WriteString(line + "\n")
+				}
+			}
+		}
+
 		if m.finalStats.DidRollback {
 			b.WriteString("\n")
 			if m.finalStats.RollbackSuccess {
@@ -163,3 +212,13 @@ func (m Model) viewError() string {
 	b.WriteString("\n\n")
 	return b.String()
 }
+
+func migrationTypeLabel(t db.MigrationType) string {
+	switch t {
+	case db.SchemaOnly:
+		return "Schema Only"
+	case db.DataOnly:
+		return "Data Only"
+	}
+	return "Full (Schema + Data)"
+}
@@ -15,20 +15,57 @@ func (m Model) viewEstimation() string {
 	b.WriteString(PromptStyle.Render("Pre-flight Checks"))
 	b.WriteString("\n\n")
 
-	if m.estimation == nil {
+	if m.estimation == nil && m.estimationErr == nil {
 		b.WriteString("   " + m.spinner.View() + " Analyzing databases...\n")
 		b.WriteString("\n")
 		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("   > psql ... SHOW server_version"))
 		b.WriteString("\n")
 		b.WriteString(m.progressBar.ViewAs(0.2))
+		b.WriteString("\n\n")
+		b.WriteString(HelpStyle.Render("esc back"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+
+	if m.estimationErr != nil {
+		b.WriteString(viewConnError(m.estimationErr))
+		if m.estimation != nil && (len(m.estimation.Checks) > 0 || m.estimation.SourceVersion != "" || m.estimation.TargetVersion != "") {
+			b.WriteString("   " + PromptStyle.Render("Checks that completed") + "\n\n")
+			b.WriteString(viewChecks(m.estimation))
+		}
 		b.WriteString("\n")
+		b.WriteString(HelpStyle.Render("r retry • e edit " + failedEndpoint(m.estimationErr) + " URL • esc back"))
+		b.WriteString("\n\n")
 		return b.String()
 	}
 
-	b.WriteString(fmt.Sprintf("   Source: %s\n", m.estimation.SourceVersion))
-	b.WriteString(fmt.Sprintf("   Target: %s\n\n", m.estimation.TargetVersion))
+	b.WriteString(viewChecks(m.estimation))
+
+	b.WriteString("\n")
+	help := "enter to continue"
+	if _, _, ok := m.directURLs(); ok {
+		help += " • d to use direct connections"
+	}
+	if m.estimation.Upgrade != nil {
+		help += " • u for upgrade report"
+	}
+	b.WriteString(HelpStyle.Render(help + " • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// viewChecks lists the server versions found and the pre-flight checks.
+func viewChecks(res *db.EstimationResult) string {
+	var b strings.Builder
+	if res.SourceVersion != "" {
+		b.WriteString(fmt.Sprintf("   Source: %s\n", res.SourceVersion))
+	}
+	if res.TargetVersion != "" {
+		b.WriteString(fmt.Sprintf("   Target: %s\n", res.TargetVersion))
+	}
+	b.WriteString("\n")
 
-	for _, c := range m.estimation.Checks {
+	for _, c := range res.Checks {
 		icon := "✓"
 		color := "2"
 		switch c.Status {
@@ -43,9 +80,91 @@ func (m Model) viewEstimation() string {
 		line := fmt.Sprintf("%s %s: %s", icon, c.Name, c.Message)
 		b.WriteString("   " + lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(line) + "\n")
 	}
+	return b.String()
+}
+
+// viewConnError shows why each connection failed, what to check, and the
+// output of psql.
+func viewConnError(err error) string {
+	var b strings.Builder
+	conns := db.ConnErrors(err)
+	if len(conns) == 0 {
+		b.WriteString("   " + ErrorMessageStyle.Render("✗ "+err.Error()) + "\n\n")
+		return b.String()
+	}
+	for _, ce := range conns {
+		heading := fmt.Sprintf("✗ %s: %s", strings.ToUpper(ce.Endpoint[:1])+ce.Endpoint[1:], ce.Kind.Label())
+		b.WriteString("   " + ErrorStyle.Render(heading) + "\n")
+		if hint := ce.Kind.Hint(); hint != "" {
+			b.WriteString("     " + hint + "\n")
+		}
+		lines := strings.Split(ce.Output, "\n")
+		if len(lines) > 6 {
+			lines = append(lines[:6], "...")
+		}
+		for _, line := range lines {
+			b.WriteString("     " + HintStyle.Render("│ "+line) + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// failedEndpoint names the first endpoint err failed to reach, the one "e"
+// edits.
+func failedEndpoint(err error) string {
+	if conns := db.ConnErrors(err); len(conns) > 0 && conns[0].Endpoint == "target" {
+		return "target"
+	}
+	return "source"
+}
+
+func (m Model) viewUpgrade() string {
+	var b strings.Builder
+	report := m.estimation.Upgrade
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render(fmt.Sprintf("Upgrade Assistant: PostgreSQL %d -> %d", report.SourceMajor, report.TargetMajor)))
+	b.WriteString("\n\n")
+
+	if len(report.Issues) == 0 {
+		b.WriteString("   " + SuccessStyle.Render("✓ No known incompatibilities found") + "\n")
+	}
+
+	const pageSize = 5
+	end := m.scrollOffset + pageSize
+	if end > len(report.Issues) {
+		end = len(report.Issues)
+	}
+	for _, issue := range report.Issues[m.scrollOffset:end] {
+		icon, color := "⚠", "3"
+		if issue.Severity == db.StatusRed {
+			icon, color = "✗", "1"
+		}
+		title := fmt.Sprintf("%s [PG %d] %s", icon, issue.Since, issue.Category)
+		if issue.Object != "" {
+			title += ": " + issue.Object
+		}
+		b.WriteString("   " + lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(title) + "\n")
+		b.WriteString("     " + issue.Problem + "\n")
+		b.WriteString("     " + HintStyle.Render("→ "+issue.Remediation) + "\n\n")
+	}
+	if len(report.Issues) > pageSize {
+		b.WriteString(fmt.Sprintf("   Showing %d-%d of %d\n", m.scrollOffset+1, end, len(report.Issues)))
+	}
+
+	for _, e := range report.Errors {
+		b.WriteString("   " + WarningStyle.Render("⚠ Not checked: "+e) + "\n")
+	}
+
+	if m.successMsg != "" {
+		b.WriteString("\n   " + SuccessStyle.Render("✓ "+m.successMsg) + "\n")
+	}
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
 
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("enter to continue"))
+	b.WriteString(HelpStyle.Render("↑/↓ scroll • s save JSON report • esc back"))
 	b.WriteString("\n\n")
 	return b.String()
 }
@@ -56,6 +175,13 @@ func (m Model) viewTableSelect() string {
 	b.WriteString(PromptStyle.Render("Select Tables (Space to toggle, A for all)"))
 	b.WriteString("\n\n")
 
+	if m.tablesErr != nil {
+		b.WriteString(viewConnError(m.tablesErr))
+		b.WriteString(HelpStyle.Render("r retry • e edit source URL • esc back"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+
 	if m.availableTables == nil {
 		b.WriteString("   " + m.spinner.View() + " Fetching tables...\n")
 		b.WriteString("\n")
@@ -97,7 +223,7 @@ func (m Model) viewTableSelect() string {
 	}
 
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("enter to confirm selection"))
+	b.WriteString(HelpStyle.Render("enter to confirm selection • esc back"))
 	b.WriteString("\n\n")
 	return b.String()
 }
@@ -115,52 +241,289 @@ func (m Model) viewOptions() string {
 		b.WriteString("\n\n")
 	}
 
-	style := UnselectedItemStyle
-	cursor := " "
-	if m.cursor == 0 {
-		style = SelectedItemStyle
-		cursor = ">"
-	}
-
 	jobsInfo := fmt.Sprintf("Parallel Jobs: %d", m.options.ParallelJobs)
 	if m.systemInfo != nil && m.options.ParallelJobs == m.systemInfo.RecommendedWorkers {
 		jobsInfo += " (recommended)"
 	}
-	if m.cursor == 0 {
+	if m.cursor == optParallelJobs {
 		jobsInfo += "  (←/→ to change)"
 	}
-	b.WriteString(style.Render(fmt.Sprintf("%s %s", cursor, jobsInfo)))
+	b.WriteString(m.optionRow(optParallelJobs, jobsInfo))
 	b.WriteString("\n")
 
-	style = UnselectedItemStyle
-	cursor = " "
-	if m.cursor == 1 {
-		style = SelectedItemStyle
-		cursor = ">"
+	backupInfo := fmt.Sprintf("Safety Backup: %s", yesNo(m.options.AutoBackup))
+	if m.cursor == optAutoBackup {
+		backupInfo += " (Space to toggle)"
 	}
+	b.WriteString(m.optionRow(optAutoBackup, backupInfo))
+	b.WriteString("\n")
 
-	backupStr := "No"
-	if m.options.AutoBackup {
-		backupStr = "Yes"
+	activityInfo := "Active Sessions on Target: " + activityLabel(m.options.OnActivity)
+	if m.cursor == optOnActivity {
+		activityInfo += "  (←/→ to change)"
 	}
-	backupInfo := fmt.Sprintf("Safety Backup: %s", backupStr)
-	if m.cursor == 1 {
-		backupInfo += " (Space to toggle)"
+	b.WriteString(m.optionRow(optOnActivity, activityInfo))
+	b.WriteString("\n")
+
+	providerInfo := "Provider Preset: " + m.providerLabel()
+	if m.cursor == optProvider {
+		providerInfo += "  (←/→ to change)"
+	}
+	b.WriteString(m.optionRow(optProvider, providerInfo))
+	b.WriteString("\n\n")
+
+	b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("   Data-only loading"))
+	b.WriteString("\n")
+
+	strategyInfo := fmt.Sprintf("Load Strategy: %s", m.options.LoadStrategy)
+	if m.cursor == optLoadStrategy {
+		strategyInfo += "  (←/→ to change)"
+	}
+	b.WriteString(m.optionRow(optLoadStrategy, strategyInfo))
+	b.WriteString("\n")
+
+	b.WriteString(m.optionRow(optTruncateCascade, fmt.Sprintf("Truncate CASCADE: %s", yesNo(m.options.TruncateCascade))))
+	b.WriteString("\n")
+
+	b.WriteString(m.optionRow(optDisableTriggers, fmt.Sprintf("Disable FK Triggers: %s", yesNo(m.options.DisableTriggers))))
+	b.WriteString("\n")
+
+	b.WriteString(m.optionRow(optTableStrategies, fmt.Sprintf("Per-table Strategies: %d override(s)", len(m.options.TableStrategies))))
+	b.WriteString("\n\n")
+
+	b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("   After restore"))
+	b.WriteString("\n")
+
+	b.WriteString(m.optionRow(optSyncSequences, fmt.Sprintf("Sync Sequences: %s", yesNo(m.options.SyncSequences))))
+	b.WriteString("\n")
+
+	analyzeInfo := "Planner Statistics: " + analyzeLabel(m.options.Analyze)
+	if m.cursor == optAnalyze {
+		analyzeInfo += "  (←/→ to change)"
 	}
-	b.WriteString(style.Render(fmt.Sprintf("%s %s", cursor, backupInfo)))
+	b.WriteString(m.optionRow(optAnalyze, analyzeInfo))
+	b.WriteString("\n")
+
+	b.WriteString(m.optionRow(optRefreshMatViews, fmt.Sprintf("Refresh Materialized Views: %s", yesNo(m.options.RefreshMatViews))))
 	b.WriteString("\n\n")
 
-	style = UnselectedItemStyle
-	cursor = " "
-	if m.cursor == 2 {
+	continueLabel := "Continue to Migration Type"
+	if m.reviewing {
+		continueLabel = "Back to Review"
+	}
+	b.WriteString(m.optionRow(optContinue, continueLabel))
+	b.WriteString("\n\n")
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render("↑/↓ or j/k to move • enter to confirm • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func (m Model) optionRow(index int, text string) string {
+	style := UnselectedItemStyle
+	cursor := " "
+	if m.cursor == index {
 		style = SelectedItemStyle
 		cursor = ">"
 	}
-	b.WriteString(style.Render(fmt.Sprintf("%s Continue to Confirmation", cursor)))
+	return style.Render(fmt.Sprintf("%s %s", cursor, text))
+}
+
+func analyzeLabel(a db.AnalyzeMode) string {
+	switch a {
+	case db.AnalyzeFull:
+		return "ANALYZE"
+	case db.AnalyzeStages:
+		return "vacuumdb --analyze-in-stages"
+	}
+	return "Off"
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "Yes"
+	}
+	return "No"
+}
+
+func (m Model) viewTableStrategy() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render("Per-table Load Strategy (data-only)"))
 	b.WriteString("\n\n")
 
+	tables := m.strategyTables()
+	if len(tables) == 0 {
+		b.WriteString("   No tables available.\n")
+	}
+
+	start := m.scrollOffset
+	end := start + 10
+	if end > len(tables) {
+		end = len(tables)
+	}
+
+	for i := start; i < end; i++ {
+		table := tables[i]
+		strategy := "default (" + string(m.options.LoadStrategy) + ")"
+		if s, ok := m.options.TableStrategies[table]; ok {
+			strategy = string(s)
+		}
+
+		style := UnselectedItemStyle
+		cursor := " "
+		if m.selectedIndex == i {
+			style = SelectedItemStyle
+			cursor = ">"
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s %-40s %s", cursor, table, strategy)) + "\n")
+	}
+
+	if len(tables) > 10 {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(fmt.Sprintf("\n   ... %d more tables (↓ to scroll)", len(tables)-end)))
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render("←/→ to change • enter to go back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func activityLabel(p db.ActivityPolicy) string {
+	switch p {
+	case db.ActivityWait:
+		return "wait until idle"
+	case db.ActivityTerminate:
+		return "terminate"
+	}
+	return "abort"
+}
+
+func (m Model) providerLabel() string {
+	p, _ := db.ResolveProvider(m.options.Provider, m.targetURL, m.sourceURL)
+	switch {
+	case m.options.Provider == "auto" && p != nil:
+		return "auto (" + p.Label + ")"
+	case m.options.Provider == "auto":
+		return "auto (none detected)"
+	case p == nil:
+		return "none"
+	}
+	return p.Label
+}
+
+func (m Model) viewConfirmRestore() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	name := db.DatabaseName(m.targetURL)
+	b.WriteString(ErrorStyle.Render(fmt.Sprintf("Overwrite %s?", name)))
+	b.WriteString("\n\n")
+	b.WriteString("   Target: " + db.RedactURL(m.targetURL) + "\n")
+	profile := db.TargetProfile(m.targetURL, m.options.Profiles)
+	if profile != nil {
+		line := "   Environment: " + profile.Label()
+		if profile.IsProtected() {
+			line = WarningStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+
+	if m.plan == nil {
+		b.WriteString("   " + m.spinner.View() + " Reading the source schema to list what will be dropped...\n")
+		b.WriteString("\n")
+		b.WriteString(HelpStyle.Render("esc back"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+
+	drops := m.plan.Drops
+	switch {
+	case len(drops) > 0:
+		b.WriteString(fmt.Sprintf("   pg_restore -c --if-exists drops and recreates %d objects (%d exist on the target):\n", len(drops), m.plan.ExistingDrops()))
+		const pageSize = 12
+		end := m.scrollOffset + pageSize
+		if end > len(drops) {
+			end = len(drops)
+		}
+		for _, o := range drops[m.scrollOffset:end] {
+			name := o.Name
+			if o.Schema != "" {
+				name = o.Schema + "." + o.Name
+			}
+			line := fmt.Sprintf("   %-18s %s", o.Type, name)
+			if o.Exists {
+				line = WarningStyle.Render(line + "  (exists)")
+			}
+			b.WriteString(line + "\n")
+		}
+		if len(drops) > pageSize {
+			b.WriteString(fmt.Sprintf("\n   Showing %d-%d of %d\n", m.scrollOffset+1, end, len(drops)))
+		}
+	case m.migrationType == db.DataOnly && m.options.LoadStrategy == db.LoadTruncate:
+		b.WriteString("   No objects are dropped; the selected tables are truncated before loading.\n")
+	default:
+		b.WriteString("   No objects are dropped.\n")
+	}
+	for _, e := range m.plan.Errors {
+		b.WriteString("   " + WarningStyle.Render("⚠ "+e) + "\n")
+	}
+
+	b.WriteString("\n")
+	if m.plan.BackupLocation != "" {
+		b.WriteString("   Backup: " + m.plan.BackupLocation + "\n")
+	} else {
+		b.WriteString("   " + WarningStyle.Render("Backup: none (rollback is not possible)") + "\n")
+	}
+
+	help := "↑/↓ scroll • enter migrate • esc back"
+	if profile.IsProtected() {
+		b.WriteString("\n")
+		if err := db.CheckProtectedTarget(m.targetURL, m.options); err != nil {
+			b.WriteString("   " + ErrorMessageStyle.Render("✗ Protected target: turn on the safety backup in the options to continue") + "\n")
+			help = "↑/↓ scroll • esc back"
+		} else {
+			b.WriteString(fmt.Sprintf("   Type %s to confirm: %s\n", name, m.confirmInput.View()))
+		}
+	}
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render(help))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func (m Model) viewConfirmTerminate() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(ErrorStyle.Render("Terminate sessions on the target?"))
+	b.WriteString("\n\n")
+	b.WriteString("   Every other connection to the target database will be closed with\n")
+	b.WriteString("   pg_terminate_backend() right before the restore starts.\n\n")
+
+	var sessions []db.TargetSession
+	if m.estimation != nil {
+		sessions = m.estimation.Sessions
+	}
+	if len(sessions) == 0 {
+		b.WriteString("   No sessions were connected during pre-flight.\n")
+	} else {
+		b.WriteString(fmt.Sprintf("   Connected during pre-flight (%d):\n", len(sessions)))
+		for i, s := range sessions {
+			if i == 10 {
+				b.WriteString(fmt.Sprintf("   ... and %d more\n", len(sessions)-i))
+				break
+			}
+			b.WriteString("   • " + s.String() + "\n")
+		}
+	}
+
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("↑/↓ or j/k to move • enter to confirm"))
+	b.WriteString(HelpStyle.Render("y to terminate and migrate • n/esc to go back"))
 	b.WriteString("\n\n")
 	return b.String()
 }
@@ -188,8 +551,150 @@ func (m Model) viewMigrationType() string {
 		b.WriteString("\n")
 	}
 
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render("↑/↓ or j/k to move • enter to confirm • p dry run • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func (m Model) viewReview() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render("Review"))
+	b.WriteString("\n\n")
+
+	target := db.RedactURL(m.targetURL)
+	if p := db.TargetProfile(m.targetURL, m.options.Profiles); p != nil {
+		target += "  [" + p.Label() + "]"
+	}
+	tables := fmt.Sprintf("all %d", len(m.availableTables))
+	if n := len(m.options.SelectedTables); n > 0 {
+		tables = fmt.Sprintf("%d of %d selected", n, len(m.availableTables))
+	}
+	options := fmt.Sprintf("%d jobs, backup %s, sessions: %s, provider: %s", m.options.ParallelJobs, yesNo(m.options.AutoBackup), activityLabel(m.options.OnActivity), m.providerLabel())
+	if m.migrationType == db.DataOnly {
+		options += ", load: " + string(m.options.LoadStrategy)
+	}
+
+	rows := []struct{ label, value string }{
+		{"Source", db.RedactURL(m.sourceURL)},
+		{"Target", target},
+		{"Tables", tables},
+		{"Options", options},
+		{"Type", migrationTypeLabel(m.migrationType)},
+	}
+	for i, r := range rows {
+		b.WriteString(m.optionRow(i, fmt.Sprintf("%-8s %s", r.label+":", r.value)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(m.optionRow(reviewContinue, "Continue to Confirmation"))
+	b.WriteString("\n")
+
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
 	b.WriteString("\n")
-	b.WriteString(HelpStyle.Render("↑/↓ or j/k to move • enter to confirm"))
+	b.WriteString(HelpStyle.Render("↑/↓ move • enter edit step or continue • p dry run • esc back"))
 	b.WriteString("\n\n")
 	return b.String()
 }
+
+func (m Model) viewPlan() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(PromptStyle.Render(fmt.Sprintf("Dry Run: %s", migrationTypeLabel(m.migrationType))))
+	b.WriteString("\n\n")
+
+	if m.plan == nil {
+		b.WriteString("   " + m.spinner.View() + " Running pre-flight and reading the source schema...\n")
+		b.WriteString("\n")
+		b.WriteString(HelpStyle.Render("esc back"))
+		b.WriteString("\n\n")
+		return b.String()
+	}
+
+	const pageSize = 18
+	lines := m.planLines()
+	end := m.scrollOffset + pageSize
+	if end > len(lines) {
+		end = len(lines)
+	}
+	for _, line := range lines[m.scrollOffset:end] {
+		b.WriteString(line + "\n")
+	}
+	if len(lines) > pageSize {
+		b.WriteString(fmt.Sprintf("\n   Showing lines %d-%d of %d\n", m.scrollOffset+1, end, len(lines)))
+	}
+
+	if m.successMsg != "" {
+		b.WriteString("\n   " + SuccessStyle.Render("✓ "+m.successMsg) + "\n")
+	}
+	if m.errorMsg != "" {
+		b.WriteString("\n   " + ErrorMessageStyle.Render("✗ "+m.errorMsg) + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(HelpStyle.Render("↑/↓ scroll • s save JSON plan • enter run migration • esc back"))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func (m Model) planLines() []string {
+	plan := m.plan
+	var lines []string
+	if plan.Blocked {
+		lines = append(lines, "   "+ErrorStyle.Render("✗ Pre-flight checks would block this migration"), "")
+	}
+
+	lines = append(lines, "   "+PromptStyle.Render("Steps"))
+	for i, s := range plan.Steps {
+		lines = append(lines, fmt.Sprintf("   %2d. %s", i+1, s.Description))
+		if s.Command != "" {
+			lines = append(lines, "       "+HintStyle.Render("$ "+s.Command))
+		}
+	}
+
+	if len(plan.Drops) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render(fmt.Sprintf("Dropped and recreated: %d objects (%d exist on target)", len(plan.Drops), plan.ExistingDrops())))
+		for _, o := range plan.Drops {
+			name := o.Name
+			if o.Schema != "" {
+				name = o.Schema + "." + o.Name
+			}
+			line := fmt.Sprintf("   %-18s %s", o.Type, name)
+			if o.Exists {
+				line = WarningStyle.Render(line + "  (exists)")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if len(plan.Tables) > 0 {
+		lines = append(lines, "", "   "+PromptStyle.Render(fmt.Sprintf("Tables: %d", len(plan.Tables))))
+		for _, t := range plan.Tables {
+			line := fmt.Sprintf("   %-40s ~%d rows  %s", t.Name, t.EstimatedRows, db.FormatBytes(t.Bytes))
+			if t.Strategy != "" {
+				line += "  " + string(t.Strategy)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	lines = append(lines, "")
+	if plan.BackupLocation != "" {
+		lines = append(lines, "   Backup: "+plan.BackupLocation)
+	} else {
+		lines = append(lines, "   "+WarningStyle.Render("Backup: none (rollback is not possible)"))
+	}
+	lines = append(lines, "   Expected duration: "+plan.ExpectedBasis)
+	for _, e := range plan.Errors {
+		lines = append(lines, "   "+WarningStyle.Render("⚠ "+e))
+	}
+	return lines
+}